@@ -0,0 +1,16 @@
+// Package docs embeds the API documentation served by the application.
+package docs
+
+import "embed"
+
+// OpenAPI is the OpenAPI document describing the HTTP API
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// SwaggerUI holds the stylesheet and script of Swagger UI 5.18.2 under
+// swagger-ui/, copied unmodified from the swagger-ui-dist package
+// (Apache License 2.0) so that the docs page loads nothing from a CDN
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+var SwaggerUI embed.FS
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval limits how often last_used_at is written for a key
+const apiKeyTouchInterval = time.Minute
+
+// APIKey represents a named API key belonging to a user
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Key        string     `json:"-" db:"-"` // Plaintext key, only set when a key is generated
+	Prefix     string     `json:"prefix" db:"key_prefix"`
+	Hash       string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Expired reports whether the key has passed its expiry time
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Revoked reports whether the key has been revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyRepositoryInterface defines the interface for API key repository
+type APIKeyRepositoryInterface interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByKey(ctx context.Context, apiKey string) (*APIKey, error)
+	GetByID(ctx context.Context, id string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	Rotate(ctx context.Context, id string, graceUntil time.Time, replacement *APIKey) error
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// apiKeyColumns lists the columns selected for an APIKey, in scan order
+const apiKeyColumns = "id, user_id, name, key_prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+// scanAPIKey scans a row selected with apiKeyColumns into key
+func scanAPIKey(row rowScanner, key *APIKey) error {
+	return row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		pq.Array(&key.Scopes),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+}
+
+// insertAPIKey stores a new key, deriving its lookup prefix and hash from
+// the plaintext key
+func insertAPIKey(ctx context.Context, q querier, key *APIKey) error {
+	key.ID = uuid.New().String()
+	key.CreatedAt = time.Now().UTC()
+	key.Prefix = apiKeyLookupPrefix(key.Key)
+	key.Hash = hashAPIKey(key.Key)
+	if len(key.Scopes) == 0 {
+		key.Scopes = []string{ScopeAll}
+	}
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, key_prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := q.ExecContext(ctx,
+		query,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.Hash,
+		pq.Array(key.Scopes),
+		key.ExpiresAt,
+		key.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// Create inserts a new API key into the database
+func (r *APIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	ctx, span := startSpan(ctx, "APIKeyRepository.Create")
+	defer span.End()
+
+	return insertAPIKey(ctx, r.db, key)
+}
+
+// GetByKey retrieves an API key by its plaintext value. Candidates are looked
+// up by the non-secret key prefix and the hash is compared in constant time.
+// Revoked and expired keys are returned so callers can tell them apart.
+func (r *APIKeyRepository) GetByKey(ctx context.Context, apiKey string) (*APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetByKey")
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_prefix = $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, apiKeyLookupPrefix(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	defer rows.Close()
+
+	hash := hashAPIKey(apiKey)
+	var match *APIKey
+	for rows.Next() {
+		var key APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) == 1 {
+			match = &key
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return match, nil
+}
+
+// GetByID retrieves an API key by its ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetByID")
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE id = $1
+	`
+
+	var key APIKey
+	err := scanAPIKey(r.db.QueryRowContext(ctx, query, id), &key)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// ListByUser retrieves all API keys of a user, oldest first
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.ListByUser")
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var key APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "APIKeyRepository.Revoke")
+	defer span.End()
+
+	query := `UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Rotate issues a replacement for an API key and shortens the old key's
+// expiry to graceUntil, in a single transaction
+func (r *APIKeyRepository) Rotate(ctx context.Context, id string, graceUntil time.Time, replacement *APIKey) error {
+	ctx, span := startSpan(ctx, "APIKeyRepository.Rotate")
+	defer span.End()
+
+	return r.db.InTransaction(ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE api_keys
+			SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+			WHERE id = $1 AND revoked_at IS NULL
+		`
+
+		result, err := tx.ExecContext(ctx, query, id, graceUntil)
+		if err != nil {
+			return fmt.Errorf("failed to expire rotated API key: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		return insertAPIKey(ctx, tx, replacement)
+	})
+}
+
+// TouchLastUsed records when an API key was last used
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	ctx, span := startSpan(ctx, "APIKeyRepository.TouchLastUsed")
+	defer span.End()
+
+	query := `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id, at); err != nil {
+		return fmt.Errorf("failed to update API key last used time: %w", err)
+	}
+
+	return nil
+}
+
+// APIKeyHandler handles HTTP requests for managing a user's API keys
+type APIKeyHandler struct {
+	keys        APIKeyRepositoryInterface
+	users       UserRepositoryInterface
+	validator   *RequestValidator
+	gracePeriod time.Duration
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(keys APIKeyRepositoryInterface, users UserRepositoryInterface, config *Config) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys:        keys,
+		users:       users,
+		validator:   NewRequestValidator(),
+		gracePeriod: config.APIKeyRotationGracePeriod,
+	}
+}
+
+// ListKeys handles GET /api/users/{id}/keys
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("list", "api_keys", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	if !h.requireUser(w, r, userID) {
+		return
+	}
+
+	keys, err := h.keys.ListByUser(r.Context(), userID)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve API keys", err)
+		return
+	}
+
+	if keys == nil {
+		keys = []APIKey{}
+	}
+
+	jsonResponse(w, http.StatusOK, ListAPIKeysResponse{
+		Keys:  keys,
+		Count: len(keys),
+	})
+}
+
+// CreateKey handles POST /api/users/{id}/keys
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("create", "api_keys", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	var req CreateAPIKeyRequest
+	if !h.validator.Decode(w, r, &req) {
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errorResponse(w, r, CodeExpiresAtInvalid, "expires_at must be in the future", nil)
+		return
+	}
+
+	// Keys can never grant more than the key used to create them
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeAll}
+		if current, ok := GetAPIKey(r.Context()); ok {
+			scopes = current.Scopes
+		}
+	}
+	if missing := missingScope(r, scopes); missing != "" {
+		scopeErrorResponse(w, r, missing)
+		return
+	}
+
+	if !h.requireUser(w, r, userID) {
+		return
+	}
+
+	key, err := newAPIKey(userID, req.Name, scopes, req.ExpiresAt)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to generate API key", err)
+		return
+	}
+
+	if err := h.keys.Create(r.Context(), key); err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to create API key", err)
+		return
+	}
+
+	AddAuditTarget(r.Context(), "key_id", key.ID)
+	jsonResponse(w, http.StatusCreated, CreateAPIKeyResponse{
+		APIKey: *key,
+		Key:    key.Key,
+	})
+}
+
+// RevokeKey handles POST /api/users/{id}/keys/{key_id}:revoke
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("revoke", "api_keys", time.Since(start))
+	}()
+
+	key, ok := h.lookupKey(w, r)
+	if !ok {
+		return
+	}
+
+	if key.Revoked() {
+		errorResponse(w, r, CodeAPIKeyAlreadyRevoked, "API key is already revoked", nil)
+		return
+	}
+
+	if err := h.keys.Revoke(r.Context(), key.ID); err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeAPIKeyAlreadyRevoked, "API key is already revoked", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to revoke API key", err)
+		return
+	}
+
+	revoked, err := h.keys.GetByID(r.Context(), key.ID)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve revoked API key", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, revoked)
+}
+
+// RotateKey handles POST /api/users/{id}/keys/{key_id}:rotate. The old key
+// keeps working for the configured grace period so that integrations can
+// switch over without downtime.
+func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("rotate", "api_keys", time.Since(start))
+	}()
+
+	var req RotateAPIKeyRequest
+	if !h.validator.DecodeOptional(w, r, &req) {
+		return
+	}
+
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		errorResponse(w, r, CodeExpiresAtInvalid, "expires_at must be in the future", nil)
+		return
+	}
+
+	key, ok := h.lookupKey(w, r)
+	if !ok {
+		return
+	}
+
+	if key.Revoked() || key.Expired(now) {
+		errorResponse(w, r, CodeAPIKeyNotActive, "Only active API keys can be rotated", nil)
+		return
+	}
+
+	// The replacement inherits the old key's scopes, which the caller must hold
+	if missing := missingScope(r, key.Scopes); missing != "" {
+		scopeErrorResponse(w, r, missing)
+		return
+	}
+
+	replacement, err := newAPIKey(key.UserID, key.Name, key.Scopes, req.ExpiresAt)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to generate API key", err)
+		return
+	}
+
+	if err := h.keys.Rotate(r.Context(), key.ID, now.Add(h.gracePeriod), replacement); err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeAPIKeyNotActive, "Only active API keys can be rotated", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to rotate API key", err)
+		return
+	}
+
+	AddAuditTarget(r.Context(), "replacement_key_id", replacement.ID)
+
+	previous, err := h.keys.GetByID(r.Context(), key.ID)
+	if err != nil || previous == nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve rotated API key", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, RotateAPIKeyResponse{
+		CreateAPIKeyResponse: CreateAPIKeyResponse{
+			APIKey: *replacement,
+			Key:    replacement.Key,
+		},
+		Previous: *previous,
+	})
+}
+
+// requireUser writes a 404 response and returns false if the user does not exist
+func (h *APIKeyHandler) requireUser(w http.ResponseWriter, r *http.Request, userID string) bool {
+	user, err := h.users.GetByID(r.Context(), userID)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve user", err)
+		return false
+	}
+
+	if user == nil {
+		errorResponse(w, r, CodeUserNotFound, "User not found", nil)
+		return false
+	}
+
+	return true
+}
+
+// lookupKey loads the key named in the route, writing a 404 response if it
+// does not exist or belongs to a different user
+func (h *APIKeyHandler) lookupKey(w http.ResponseWriter, r *http.Request) (*APIKey, bool) {
+	vars := mux.Vars(r)
+
+	key, err := h.keys.GetByID(r.Context(), vars["key_id"])
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve API key", err)
+		return nil, false
+	}
+
+	if key == nil || key.UserID != vars["id"] {
+		errorResponse(w, r, CodeAPIKeyNotFound, "API key not found", nil)
+		return nil, false
+	}
+
+	return key, true
+}
+
+// newAPIKey generates a named, scoped key for a user
+func newAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*APIKey, error) {
+	plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Key:       plaintext,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}, nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockAPIKeyRepository implements APIKeyRepositoryInterface for testing
+type MockAPIKeyRepository struct {
+	keys    map[string]*APIKey
+	counter int
+}
+
+func NewMockAPIKeyRepository() *MockAPIKeyRepository {
+	return &MockAPIKeyRepository{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	m.counter++
+	key.ID = fmt.Sprintf("key-%d", m.counter)
+	key.CreatedAt = time.Now().Add(time.Duration(m.counter) * time.Millisecond)
+	key.Prefix = apiKeyLookupPrefix(key.Key)
+	key.Hash = hashAPIKey(key.Key)
+	if len(key.Scopes) == 0 {
+		key.Scopes = []string{ScopeAll}
+	}
+	stored := *key
+	m.keys[key.ID] = &stored
+	return nil
+}
+
+func (m *MockAPIKeyRepository) GetByKey(ctx context.Context, apiKey string) (*APIKey, error) {
+	hash := hashAPIKey(apiKey)
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockAPIKeyRepository) GetByID(ctx context.Context, id string) (*APIKey, error) {
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *key
+	return &found, nil
+}
+
+func (m *MockAPIKeyRepository) ListByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, key := range m.keys {
+		if key.UserID == userID {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	key, ok := m.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	return nil
+}
+
+func (m *MockAPIKeyRepository) Rotate(ctx context.Context, id string, graceUntil time.Time, replacement *APIKey) error {
+	key, ok := m.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	if key.ExpiresAt == nil || graceUntil.Before(*key.ExpiresAt) {
+		key.ExpiresAt = &graceUntil
+	}
+	return m.Create(ctx, replacement)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	if key, ok := m.keys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
+
+func TestAPIKeyManagement(t *testing.T) {
+	router, userRepo := setupUserTest(t)
+	const adminKey = "test-admin-key-123"
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	createUser := func(username string) CreateUserResponse {
+		w := serve("POST", "/api/users", adminKey, `{"username":"`+username+`"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create user %s: %d %s", username, w.Code, w.Body.String())
+		}
+		var created CreateUserResponse
+		json.Unmarshal(w.Body.Bytes(), &created)
+		return created
+	}
+
+	alice := createUser("alice")
+	bob := createUser("bob")
+	keysPath := "/api/users/" + alice.ID + "/keys"
+
+	t.Run("List own keys", func(t *testing.T) {
+		w := serve("GET", keysPath, alice.APIKey, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var response ListAPIKeysResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Count != 1 || response.Keys[0].Name != "default" {
+			t.Fatalf("Expected the default key, got %+v", response)
+		}
+		if response.Keys[0].LastUsedAt == nil {
+			t.Error("Expected last_used_at to be recorded")
+		}
+		if strings.Contains(w.Body.String(), alice.APIKey) {
+			t.Error("Expected plaintext key to be omitted from listing")
+		}
+	})
+
+	t.Run("Other users cannot manage keys", func(t *testing.T) {
+		if w := serve("GET", keysPath, bob.APIKey, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+		if w := serve("GET", keysPath, adminKey, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected admin to list keys, got %d", w.Code)
+		}
+	})
+
+	t.Run("Reject expiry in the past", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		w := serve("POST", keysPath, alice.APIKey, `{"name":"ci","expires_at":"`+past+`"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	w := serve("POST", keysPath, alice.APIKey, `{"name":"ci","expires_at":"`+future+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Response: %s", w.Code, w.Body.String())
+	}
+	var ci CreateAPIKeyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &ci); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if ci.Name != "ci" || !strings.HasPrefix(ci.Key, apiKeyPrefix) || ci.ExpiresAt == nil {
+		t.Fatalf("Unexpected key response: %+v", ci)
+	}
+
+	t.Run("Named key authenticates", func(t *testing.T) {
+		if w := serve("GET", "/api/events", ci.Key, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Rotate keeps old key valid for grace period", func(t *testing.T) {
+		w := serve("POST", keysPath+"/"+ci.ID+":rotate", alice.APIKey, "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var rotated RotateAPIKeyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if rotated.ID == ci.ID || rotated.Name != "ci" || rotated.Previous.ID != ci.ID {
+			t.Fatalf("Unexpected rotation response: %+v", rotated)
+		}
+		if rotated.Previous.ExpiresAt == nil || rotated.Previous.ExpiresAt.After(time.Now().Add(time.Hour)) {
+			t.Errorf("Expected previous key to expire within the grace period, got %v", rotated.Previous.ExpiresAt)
+		}
+
+		if w := serve("GET", "/api/events", rotated.Key, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected replacement key to authenticate, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", ci.Key, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected old key to work during grace period, got %d", w.Code)
+		}
+
+		// Simulate the grace period elapsing
+		expired := time.Now().Add(-time.Second)
+		userRepo.keys.keys[ci.ID].ExpiresAt = &expired
+		if w := serve("GET", "/api/events", ci.Key, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected expired key to be rejected, got %d", w.Code)
+		}
+		if w := serve("POST", keysPath+"/"+ci.ID+":rotate", alice.APIKey, ""); w.Code != http.StatusConflict {
+			t.Errorf("Expected rotating an expired key to conflict, got %d", w.Code)
+		}
+	})
+
+	t.Run("Revoke key", func(t *testing.T) {
+		if w := serve("POST", keysPath+"/"+alice.ID+":revoke", alice.APIKey, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for unknown key, got %d", w.Code)
+		}
+
+		keys, _ := userRepo.keys.ListByUser(context.Background(), alice.ID)
+		defaultKey := keys[0]
+
+		w := serve("POST", keysPath+"/"+defaultKey.ID+":revoke", adminKey, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+		if w := serve("GET", "/api/events", alice.APIKey, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected revoked key to be rejected, got %d", w.Code)
+		}
+		if w := serve("POST", keysPath+"/"+defaultKey.ID+":revoke", adminKey, ""); w.Code != http.StatusConflict {
+			t.Errorf("Expected status 409 for already revoked key, got %d", w.Code)
+		}
+	})
+
+	t.Run("Keys of other users are not found", func(t *testing.T) {
+		keys, _ := userRepo.keys.ListByUser(context.Background(), bob.ID)
+		if w := serve("POST", keysPath+"/"+keys[0].ID+":revoke", adminKey, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestAPIKeyLookupPrefix(t *testing.T) {
+	tests := []struct {
+		apiKey string
+		prefix string
+	}{
+		{apiKey: "short", prefix: "short"},
+		{apiKey: "0123456789abcdef", prefix: "0123456789ab"},
+		// Keys created before hashing may hold any text; the prefix must match
+		// the character-based LEFT(api_key, 12) of migration 010
+		{apiKey: "clé-secrète-très-longue", prefix: "clé-secrète-"},
+	}
+
+	for _, tt := range tests {
+		if prefix := apiKeyLookupPrefix(tt.apiKey); prefix != tt.prefix {
+			t.Errorf("apiKeyLookupPrefix(%q) = %q, want %q", tt.apiKey, prefix, tt.prefix)
+		}
+	}
+}
+
+func TestAPIKeyRotationGracePeriodZeroFromSecrets(t *testing.T) {
+	t.Setenv("DOPPLER_SECRETS_JSON", `{"TF_VAR_api_key_rotation_grace_period":"0s"}`)
+	t.Setenv("API_KEY_ROTATION_GRACE_PERIOD", "1h")
+
+	c := &Config{}
+	if err := c.loadFromDopplerSecrets(); err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	c.loadFromEnv()
+
+	if c.APIKeyRotationGracePeriod != 0 {
+		t.Errorf("Expected an explicit grace period of 0 to be kept, got %v", c.APIKeyRotationGracePeriod)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+const (
+	defaultAuditListLimit = 100
+	maxAuditListLimit     = 1000
+)
+
+// AuditEntry records a single mutating API call
+type AuditEntry struct {
+	ID            string            `json:"id"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	ActorID       string            `json:"actor_id,omitempty"`
+	ActorUsername string            `json:"actor_username,omitempty"`
+	Method        string            `json:"method"`
+	Route         string            `json:"route"`
+	Path          string            `json:"path"`
+	Resource      string            `json:"resource"`
+	TargetIDs     map[string]string `json:"target_ids"`
+	RequestID     string            `json:"request_id,omitempty"`
+	SourceIP      string            `json:"source_ip"`
+	Status        int               `json:"status"`
+	Outcome       string            `json:"outcome"`
+}
+
+// AuditFilter narrows down the entries returned by AuditRepository.List
+type AuditFilter struct {
+	Actor    string // Matches the actor's user ID or username
+	Resource string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+// ListAuditEntriesResponse represents the response for querying the audit log
+type ListAuditEntriesResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Count   int          `json:"count"`
+}
+
+// AuditRepositoryInterface defines the interface for audit log operations
+type AuditRepositoryInterface interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	List(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+// AuditRepository handles database operations for the audit log
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// auditColumns lists the columns selected for an AuditEntry, in scan order
+const auditColumns = "id, occurred_at, actor_id, actor_username, method, route, path, resource, target_ids, request_id, source_ip, status, outcome"
+
+// scanAuditEntry scans a row selected with auditColumns into entry
+func scanAuditEntry(row rowScanner, entry *AuditEntry) error {
+	var targetIDs []byte
+	err := row.Scan(
+		&entry.ID,
+		&entry.OccurredAt,
+		&entry.ActorID,
+		&entry.ActorUsername,
+		&entry.Method,
+		&entry.Route,
+		&entry.Path,
+		&entry.Resource,
+		&targetIDs,
+		&entry.RequestID,
+		&entry.SourceIP,
+		&entry.Status,
+		&entry.Outcome,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(targetIDs, &entry.TargetIDs)
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, entry *AuditEntry) error {
+	ctx, span := startSpan(ctx, "AuditRepository.Create")
+	defer span.End()
+
+	entry.ID = uuid.New().String()
+
+	targetIDs, err := json.Marshal(entry.TargetIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit target IDs: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_log (` + auditColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	_, err = r.db.ExecContext(ctx,
+		query,
+		entry.ID,
+		entry.OccurredAt,
+		entry.ActorID,
+		entry.ActorUsername,
+		entry.Method,
+		entry.Route,
+		entry.Path,
+		entry.Resource,
+		targetIDs,
+		entry.RequestID,
+		entry.SourceIP,
+		entry.Status,
+		entry.Outcome,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List retrieves audit entries matching filter, most recent first
+func (r *AuditRepository) List(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	ctx, span := startSpan(ctx, "AuditRepository.List")
+	defer span.End()
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.Actor != "" {
+		where("(actor_id = ? OR actor_username = ?)", filter.Actor)
+	}
+	if filter.Resource != "" {
+		where("resource = ?", filter.Resource)
+	}
+	if filter.From != nil {
+		where("occurred_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		where("occurred_at < ?", *filter.To)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += ` ORDER BY occurred_at DESC, id LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		if err := scanAuditEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+const auditEntryContextKey contextKey = "audit_entry"
+
+// AddAuditTarget records the ID of a resource affected by the request, such
+// as the ID of a newly created resource that is not part of the route
+func AddAuditTarget(ctx context.Context, name, id string) {
+	if entry, ok := ctx.Value(auditEntryContextKey).(*AuditEntry); ok {
+		entry.TargetIDs[name] = id
+	}
+}
+
+// setAuditActor attributes the audit entry of the request to user
+func setAuditActor(ctx context.Context, user *User) {
+	if entry, ok := ctx.Value(auditEntryContextKey).(*AuditEntry); ok {
+		entry.ActorID = user.ID
+		entry.ActorUsername = user.Username
+	}
+}
+
+// AuditMiddleware records every mutating API call in the audit log
+type AuditMiddleware struct {
+	repo AuditRepositoryInterface
+}
+
+// NewAuditMiddleware creates a new audit middleware
+func NewAuditMiddleware(repo AuditRepositoryInterface) *AuditMiddleware {
+	return &AuditMiddleware{repo: repo}
+}
+
+// Record writes an audit entry for each non-GET request once it has been
+// handled. It must run before authentication so that rejected requests are
+// recorded too; the actor is filled in when authentication succeeds.
+func (m *AuditMiddleware) Record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &AuditEntry{
+			OccurredAt: time.Now().UTC(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			TargetIDs:  make(map[string]string),
+			RequestID:  GetRequestID(r.Context()),
+			SourceIP:   clientIP(r),
+		}
+
+		entry.Route = r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				entry.Route = template
+			}
+		}
+		entry.Resource = auditResource(entry.Route)
+
+		for name, value := range mux.Vars(r) {
+			entry.TargetIDs[name] = value
+		}
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), auditEntryContextKey, entry)))
+
+		entry.Status = rw.statusCode
+		entry.Outcome = AuditOutcomeSuccess
+		if rw.statusCode >= http.StatusBadRequest {
+			entry.Outcome = AuditOutcomeFailure
+		}
+
+		if err := m.repo.Create(context.WithoutCancel(r.Context()), entry); err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to write audit entry",
+				zap.String("method", entry.Method),
+				zap.String("path", entry.Path),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+// auditResource derives the resource name from a route template: the last
+// path segment that is not a variable, without any custom action. For
+// example "/api/users/{id}/keys/{key_id}:rotate" yields "keys".
+func auditResource(route string) string {
+	resource := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(route, "/api/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			continue
+		}
+		if name, _, found := strings.Cut(segment, ":"); found {
+			segment = name
+		}
+		if segment != "" {
+			resource = segment
+		}
+	}
+	return resource
+}
+
+// AuditHandler handles HTTP requests for querying the audit log
+type AuditHandler struct {
+	repo AuditRepositoryInterface
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(repo AuditRepositoryInterface) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+// ListEntries handles GET /api/audit
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("list", "audit_log", time.Since(start))
+	}()
+
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Actor:    query.Get("actor"),
+		Resource: query.Get("resource"),
+		Limit:    defaultAuditListLimit,
+	}
+
+	for param, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errorResponse(w, r, CodeInvalidQueryParam, fmt.Sprintf("Query parameter '%s' must be an RFC 3339 timestamp", param), err)
+			return
+		}
+		*bound = &t
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxAuditListLimit {
+			errorResponse(w, r, CodeInvalidQueryParam, fmt.Sprintf("Query parameter 'limit' must be between 1 and %d", maxAuditListLimit), nil)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.repo.List(r.Context(), filter)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve audit log", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, ListAuditEntriesResponse{
+		Entries: entries,
+		Count:   len(entries),
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MockAuditRepository implements AuditRepositoryInterface for testing
+type MockAuditRepository struct {
+	entries []AuditEntry
+}
+
+func (m *MockAuditRepository) Create(ctx context.Context, entry *AuditEntry) error {
+	entry.ID = fmt.Sprintf("audit-%d", len(m.entries)+1)
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *MockAuditRepository) List(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	for _, entry := range m.entries {
+		if filter.Actor != "" && entry.ActorID != filter.Actor && entry.ActorUsername != filter.Actor {
+			continue
+		}
+		if filter.Resource != "" && entry.Resource != filter.Resource {
+			continue
+		}
+		if filter.From != nil && entry.OccurredAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !entry.OccurredAt.Before(*filter.To) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].OccurredAt.After(entries[j].OccurredAt)
+	})
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+func TestAuditResource(t *testing.T) {
+	tests := map[string]string{
+		"/api/events":              "events",
+		"/api/events:batch":        "events",
+		"/api/events/{id}:restore": "events",
+		"/api/events/{id}/revisions/{revision:[0-9]+}:revert": "revisions",
+		"/api/users/{id}/keys/{key_id}:rotate":                "keys",
+		"/api/auth/lockouts/{ip}":                             "lockouts",
+	}
+
+	for route, expected := range tests {
+		if resource := auditResource(route); resource != expected {
+			t.Errorf("auditResource(%q) = %q, expected %q", route, resource, expected)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	config := &Config{
+		BootstrapAdminKey: "test-admin-key-123",
+		APIKeyHeader:      "X-API-Key",
+		Environment:       "test",
+	}
+
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	auditRepo := &MockAuditRepository{}
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config)
+	if err := authMiddleware.ReconcileBootstrapAdmin(context.Background()); err != nil {
+		t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+	}
+	userHandler := NewUserHandler(userRepo)
+	auditHandler := NewAuditHandler(auditRepo)
+
+	r := mux.NewRouter()
+	r.Use(RequestIDMiddleware)
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(NewAuditMiddleware(auditRepo).Record)
+	api.Use(authMiddleware.RequireAuthentication)
+
+	users := api.PathPrefix("/users").Subrouter()
+	users.Use(authMiddleware.RequireAdmin)
+	users.HandleFunc("", userHandler.ListUsers).Methods("GET")
+	users.HandleFunc("", userHandler.CreateUser).Methods("POST")
+	users.HandleFunc("/{id}:disable", userHandler.DisableUser).Methods("POST")
+
+	audit := api.PathPrefix("/audit").Subrouter()
+	audit.Use(authMiddleware.RequireAdmin)
+	audit.HandleFunc("", auditHandler.ListEntries).Methods("GET")
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", "req-"+method)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/users", config.BootstrapAdminKey, `{"username":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create user: %d %s", w.Code, w.Body.String())
+	}
+	var alice CreateUserResponse
+	json.Unmarshal(w.Body.Bytes(), &alice)
+
+	t.Run("Record mutating calls", func(t *testing.T) {
+		if len(auditRepo.entries) != 1 {
+			t.Fatalf("Expected 1 audit entry, got %d", len(auditRepo.entries))
+		}
+
+		entry := auditRepo.entries[0]
+		if entry.ActorUsername != bootstrapAdminUsername || entry.ActorID == "" {
+			t.Errorf("Expected admin actor, got %q (%q)", entry.ActorUsername, entry.ActorID)
+		}
+		if entry.Method != "POST" || entry.Route != "/api/users" || entry.Resource != "users" {
+			t.Errorf("Unexpected route: %s %s (%s)", entry.Method, entry.Route, entry.Resource)
+		}
+		if entry.TargetIDs["id"] != alice.ID {
+			t.Errorf("Expected target ID %s, got %v", alice.ID, entry.TargetIDs)
+		}
+		if entry.RequestID != "req-POST" || entry.SourceIP == "" {
+			t.Errorf("Expected request ID and source IP, got %q and %q", entry.RequestID, entry.SourceIP)
+		}
+		if entry.Status != http.StatusCreated || entry.Outcome != AuditOutcomeSuccess {
+			t.Errorf("Expected successful outcome, got %d %s", entry.Status, entry.Outcome)
+		}
+	})
+
+	t.Run("Skip reads", func(t *testing.T) {
+		serve("GET", "/api/users", config.BootstrapAdminKey, "")
+		if len(auditRepo.entries) != 1 {
+			t.Errorf("Expected GET requests not to be audited, got %d entries", len(auditRepo.entries))
+		}
+	})
+
+	t.Run("Record failed calls", func(t *testing.T) {
+		serve("POST", "/api/users/"+alice.ID+":disable", "wrong-key", "")
+		serve("POST", "/api/users/"+alice.ID+":disable", alice.APIKey, "")
+
+		unauthenticated := auditRepo.entries[1]
+		if unauthenticated.ActorID != "" || unauthenticated.Status != http.StatusUnauthorized || unauthenticated.Outcome != AuditOutcomeFailure {
+			t.Errorf("Unexpected entry for unauthenticated call: %+v", unauthenticated)
+		}
+		if unauthenticated.Route != "/api/users/{id}:disable" || unauthenticated.TargetIDs["id"] != alice.ID {
+			t.Errorf("Expected route template and target ID, got %s %v", unauthenticated.Route, unauthenticated.TargetIDs)
+		}
+
+		forbidden := auditRepo.entries[2]
+		if forbidden.ActorUsername != "alice" || forbidden.Status != http.StatusForbidden {
+			t.Errorf("Unexpected entry for forbidden call: %+v", forbidden)
+		}
+	})
+
+	t.Run("Query with filters", func(t *testing.T) {
+		list := func(query string) ListAuditEntriesResponse {
+			t.Helper()
+			w := serve("GET", "/api/audit"+query, config.BootstrapAdminKey, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+			}
+			var response ListAuditEntriesResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			return response
+		}
+
+		if response := list(""); response.Count != 3 {
+			t.Errorf("Expected 3 entries, got %d", response.Count)
+		}
+		if response := list("?actor=alice"); response.Count != 1 {
+			t.Errorf("Expected 1 entry for alice, got %d", response.Count)
+		}
+		if response := list("?resource=users&limit=2"); response.Count != 2 {
+			t.Errorf("Expected limit to apply, got %d", response.Count)
+		}
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		if response := list("?from=" + future); response.Count != 0 {
+			t.Errorf("Expected no entries after %s, got %d", future, response.Count)
+		}
+	})
+
+	t.Run("Reject invalid filters", func(t *testing.T) {
+		for _, query := range []string{"?from=yesterday", "?to=2024-13-01", "?limit=0", "?limit=5000"} {
+			if w := serve("GET", "/api/audit"+query, config.BootstrapAdminKey, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", query, w.Code)
+			}
+		}
+	})
+
+	t.Run("Admins only", func(t *testing.T) {
+		if w := serve("GET", "/api/audit", alice.APIKey, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
@@ -2,25 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// User roles
+const (
+	RoleAdmin   = "admin"
+	RoleMember  = "member"
+	RoleService = "service"
 )
 
 // User represents a system user with API access
 type User struct {
 	ID        string    `json:"id" db:"id"`
 	Username  string    `json:"username" db:"username"`
-	APIKey    string    `json:"api_key" db:"api_key"`
+	Role      string    `json:"role" db:"role"`
+	Disabled  bool      `json:"disabled" db:"disabled"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// UserRepositoryInterface defines the interface for user repository
+type UserRepositoryInterface interface {
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetByID(ctx context.Context, id string) (*User, error)
+	GetByIdentity(ctx context.Context, issuer, subject string) (*User, error)
+	GetBootstrapAdmin(ctx context.Context) (*User, error)
+	Create(ctx context.Context, user *User, key *APIKey) error
+	CreateWithIdentity(ctx context.Context, user *User, issuer, subject string) error
+	List(ctx context.Context) ([]User, error)
+	SetDisabled(ctx context.Context, id string, disabled bool) error
+	SetRole(ctx context.Context, id string, role string) error
+	SetBootstrapAdmin(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+}
+
 // UserRepository handles database operations for users
 type UserRepository struct {
 	db *DB
@@ -31,76 +57,181 @@ func NewUserRepository(db *DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// GetByAPIKey retrieves a user by their API key
-func (r *UserRepository) GetByAPIKey(apiKey string) (*User, error) {
-	query := `
-		SELECT id, username, api_key, created_at, updated_at
-		FROM users
-		WHERE api_key = $1
-	`
+// userColumns lists the columns selected for a User, in scan order
+const userColumns = "id, username, role, disabled, created_at, updated_at"
 
-	var user User
-	err := r.db.QueryRow(query, apiKey).Scan(
+// scanUser scans a row selected with userColumns into user
+func scanUser(row rowScanner, user *User) error {
+	return row.Scan(
 		&user.ID,
 		&user.Username,
-		&user.APIKey,
+		&user.Role,
+		&user.Disabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
+}
+
+// GetByUsername retrieves a user by their username
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByUsername")
+	defer span.End()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE username = $1
+	`
+
+	var user User
+	err := scanUser(r.db.QueryRowContext(ctx, query, username), &user)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by API key: %w", err)
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
 
 	return &user, nil
 }
 
-// GetByUsername retrieves a user by their username
-func (r *UserRepository) GetByUsername(username string) (*User, error) {
+// GetByID retrieves a user by their ID
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByID")
+	defer span.End()
+
 	query := `
-		SELECT id, username, api_key, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE username = $1
+		WHERE id = $1
 	`
 
 	var user User
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.APIKey,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := scanUser(r.db.QueryRowContext(ctx, query, id), &user)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by username: %w", err)
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetByIdentity retrieves the user bound to a JWT issuer and subject
+func (r *UserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetByIdentity")
+	defer span.End()
+
+	query := `
+		SELECT u.id, u.username, u.role, u.disabled, u.created_at, u.updated_at
+		FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`
+
+	var user User
+	err := scanUser(r.db.QueryRowContext(ctx, query, issuer, subject), &user)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
 	}
 
 	return &user, nil
 }
 
-// Create inserts a new user into the database
-func (r *UserRepository) Create(user *User) error {
+// GetBootstrapAdmin retrieves the user flagged as the bootstrap admin
+func (r *UserRepository) GetBootstrapAdmin(ctx context.Context) (*User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetBootstrapAdmin")
+	defer span.End()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE bootstrap_admin
+	`
+
+	var user User
+	err := scanUser(r.db.QueryRowContext(ctx, query), &user)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap admin: %w", err)
+	}
+
+	return &user, nil
+}
+
+// Create inserts a new user into the database together with their first
+// API key, if any
+func (r *UserRepository) Create(ctx context.Context, user *User, key *APIKey) error {
+	ctx, span := startSpan(ctx, "UserRepository.Create")
+	defer span.End()
+
+	return r.db.InTransaction(ctx, func(tx *sql.Tx) error {
+		if err := insertUser(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if key == nil {
+			return nil
+		}
+
+		key.UserID = user.ID
+		return insertAPIKey(ctx, tx, key)
+	})
+}
+
+// CreateWithIdentity inserts a new user bound to a JWT issuer and subject
+func (r *UserRepository) CreateWithIdentity(ctx context.Context, user *User, issuer, subject string) error {
+	ctx, span := startSpan(ctx, "UserRepository.CreateWithIdentity")
+	defer span.End()
+
+	return r.db.InTransaction(ctx, func(tx *sql.Tx) error {
+		if err := insertUser(ctx, tx, user); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO user_identities (issuer, subject, user_id, created_at)
+			VALUES ($1, $2, $3, $4)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, issuer, subject, user.ID, user.CreatedAt); err != nil {
+			return fmt.Errorf("failed to create user identity: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// insertUser inserts user, assigning its ID and timestamps
+func insertUser(ctx context.Context, q querier, user *User) error {
 	user.ID = uuid.New().String()
 	user.CreatedAt = time.Now().UTC()
 	user.UpdatedAt = user.CreatedAt
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
 
 	query := `
-		INSERT INTO users (id, username, api_key, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (id, username, role, disabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 
-	_, err := r.db.Exec(
+	_, err := q.ExecContext(ctx,
 		query,
 		user.ID,
 		user.Username,
-		user.APIKey,
+		user.Role,
+		user.Disabled,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -112,103 +243,479 @@ func (r *UserRepository) Create(user *User) error {
 	return nil
 }
 
-// AuthMiddleware provides API key authentication
+// List retrieves all users ordered by username
+func (r *UserRepository) List(ctx context.Context) ([]User, error) {
+	ctx, span := startSpan(ctx, "UserRepository.List")
+	defer span.End()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		ORDER BY username ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetDisabled enables or disables a user account
+func (r *UserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	ctx, span := startSpan(ctx, "UserRepository.SetDisabled")
+	defer span.End()
+
+	query := `UPDATE users SET disabled = $2, updated_at = $3 WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, disabled, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SetRole changes the role of a user
+func (r *UserRepository) SetRole(ctx context.Context, id string, role string) error {
+	ctx, span := startSpan(ctx, "UserRepository.SetRole")
+	defer span.End()
+
+	query := `UPDATE users SET role = $2, updated_at = $3 WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, role, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SetBootstrapAdmin flags a user as the bootstrap admin
+func (r *UserRepository) SetBootstrapAdmin(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "UserRepository.SetBootstrapAdmin")
+	defer span.End()
+
+	query := `UPDATE users SET bootstrap_admin = TRUE, updated_at = $2 WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to flag bootstrap admin: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Delete removes a user from the database
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "UserRepository.Delete")
+	defer span.End()
+
+	query := `DELETE FROM users WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// apiKeyLookupPrefixLength is the number of leading key characters stored in
+// plaintext to narrow down hash comparisons
+const apiKeyLookupPrefixLength = 12
+
+// apiKeyLookupPrefix returns the non-secret lookup prefix of an API key. It
+// counts characters rather than bytes, like Postgres' LEFT() and VARCHAR(12)
+// that migration 010 used to derive prefixes for existing keys.
+func apiKeyLookupPrefix(apiKey string) string {
+	runes := []rune(apiKey)
+	if len(runes) <= apiKeyLookupPrefixLength {
+		return apiKey
+	}
+	return string(runes[:apiKeyLookupPrefixLength])
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 hash of an API key. Generated
+// keys carry 256 bits of entropy, so a fast hash is sufficient and keeps
+// per-request authentication cheap.
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identity of the bootstrap administrator and its configured key
+const (
+	bootstrapAdminUsername = "admin"
+	bootstrapKeyName       = "bootstrap"
+)
+
+// AuthMiddleware provides API key and JWT bearer authentication
 type AuthMiddleware struct {
-	userRepo *UserRepository
-	config   *Config
+	userRepo    UserRepositoryInterface
+	apiKeyRepo  APIKeyRepositoryInterface
+	jwtVerifier *JWTVerifier
+	failures    *AuthFailureTracker
+	config      *Config
 }
 
 // NewAuthMiddleware creates a new authentication middleware
-func NewAuthMiddleware(userRepo *UserRepository, config *Config) *AuthMiddleware {
+func NewAuthMiddleware(userRepo UserRepositoryInterface, apiKeyRepo APIKeyRepositoryInterface, failures *AuthFailureTracker, config *Config) *AuthMiddleware {
 	return &AuthMiddleware{
-		userRepo: userRepo,
-		config:   config,
+		userRepo:    userRepo,
+		apiKeyRepo:  apiKeyRepo,
+		jwtVerifier: NewJWTVerifier(config),
+		failures:    failures,
+		config:      config,
 	}
 }
 
+// RequireAuthentication middleware that accepts either a JWT bearer token,
+// when configured, or an API key. Clients locked out after repeated failed
+// attempts are rejected before their credentials are checked.
+func (a *AuthMiddleware) RequireAuthentication(next http.Handler) http.Handler {
+	requireAPIKey := a.RequireAPIKey(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.rejectLockedOut(w, r) {
+			return
+		}
+
+		if a.jwtVerifier != nil && r.Header.Get(a.config.APIKeyHeader) == "" {
+			if token, ok := bearerToken(r); ok {
+				a.requireJWT(w, r, token, next)
+				return
+			}
+		}
+
+		requireAPIKey.ServeHTTP(w, r)
+	})
+}
+
 // RequireAPIKey middleware that validates API key
 func (a *AuthMiddleware) RequireAPIKey(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := LoggerFromContext(r.Context())
+
 		apiKey := r.Header.Get(a.config.APIKeyHeader)
 		if apiKey == "" {
-			log.Printf("❌ Missing API key in header %s", a.config.APIKeyHeader)
-			errorResponse(w, http.StatusUnauthorized, "API key required", nil)
+			log.Info("Missing API key", zap.String("header", a.config.APIKeyHeader))
+			a.authFailed(w, r, authFailureMissingCredentials, CodeAuthenticationRequired, "API key required")
 			return
 		}
 
-		// Check if it's the bootstrap admin key
-		if a.config.BootstrapAdminKey != "" && apiKey == a.config.BootstrapAdminKey {
-			log.Printf("✅ Bootstrap admin key used for authentication")
-			// Create a virtual admin user for the context
-			adminUser := &User{
-				ID:       "bootstrap-admin",
-				Username: "admin",
-				APIKey:   a.config.BootstrapAdminKey,
-			}
-			r = r.WithContext(WithUser(r.Context(), adminUser))
-			next.ServeHTTP(w, r)
+		// Look up the API key and its owner
+		key, err := a.apiKeyRepo.GetByKey(r.Context(), apiKey)
+		if err != nil {
+			log.Error("Failed to lookup API key", zap.Error(err))
+			errorResponse(w, r, CodeInternalError, "Authentication error", nil)
+			return
+		}
+
+		if key == nil {
+			log.Info("Invalid API key provided")
+			a.authFailed(w, r, authFailureInvalidKey, CodeAPIKeyInvalid, "Invalid API key")
 			return
 		}
 
-		// Look up user by API key
-		user, err := a.userRepo.GetByAPIKey(apiKey)
+		now := time.Now().UTC()
+		if key.Revoked() {
+			log.Info("Revoked API key used", zap.String("key_prefix", key.Prefix))
+			a.authFailed(w, r, authFailureRevokedKey, CodeAPIKeyRevoked, "API key has been revoked")
+			return
+		}
+
+		if key.Expired(now) {
+			log.Info("Expired API key used", zap.String("key_prefix", key.Prefix))
+			a.authFailed(w, r, authFailureExpiredKey, CodeAPIKeyExpired, "API key has expired")
+			return
+		}
+
+		user, err := a.userRepo.GetByID(r.Context(), key.UserID)
 		if err != nil {
-			log.Printf("❌ Failed to lookup user by API key: %v", err)
-			errorResponse(w, http.StatusInternalServerError, "Authentication error", err)
+			log.Error("Failed to lookup user by API key", zap.Error(err))
+			errorResponse(w, r, CodeInternalError, "Authentication error", nil)
 			return
 		}
 
 		if user == nil {
-			log.Printf("❌ Invalid API key provided")
-			errorResponse(w, http.StatusUnauthorized, "Invalid API key", nil)
+			log.Warn("API key without user provided", zap.String("key_prefix", key.Prefix))
+			a.authFailed(w, r, authFailureInvalidKey, CodeAPIKeyInvalid, "Invalid API key")
+			return
+		}
+
+		if user.Disabled {
+			log.Info("Disabled user attempted to authenticate", zap.String("username", user.Username))
+			a.authFailed(w, r, authFailureDisabledUser, CodeUserDisabled, "User account is disabled")
 			return
 		}
 
-		log.Printf("✅ User authenticated: %s", user.Username)
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+			if err := a.apiKeyRepo.TouchLastUsed(r.Context(), key.ID, now); err != nil {
+				log.Warn("Failed to record API key usage", zap.Error(err))
+			}
+		}
+
+		setAuditActor(r.Context(), user)
+		log = log.With(zap.String("user_id", user.ID))
+		log.Debug("User authenticated", zap.String("username", user.Username), zap.String("key_name", key.Name))
 
-		// Add user to request context
-		r = r.WithContext(WithUser(r.Context(), user))
+		// Add user, key and a logger tagged with the user to request context
+		ctx := WithUser(r.Context(), user)
+		ctx = WithAPIKey(ctx, key)
+		ctx = WithRequestLogger(ctx, log)
+		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
 
-// CreateBootstrapUser creates the bootstrap admin user if it doesn't exist
-func (a *AuthMiddleware) CreateBootstrapUser() error {
+// RequireAdmin middleware that restricts access to administrators.
+// It must run after RequireAPIKey.
+func (a *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			errorResponse(w, r, CodeAuthenticationRequired, "API key required", nil)
+			return
+		}
+
+		if !isAdmin(user) {
+			LoggerFromContext(r.Context()).Info("Non-admin user attempted admin access", zap.String("username", user.Username))
+			errorResponse(w, r, CodeAdminRequired, "Administrator access required", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireSelfOrAdmin middleware that restricts access to routes under
+// /users/{id} to that user and administrators. It must run after RequireAPIKey.
+func (a *AuthMiddleware) RequireSelfOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			errorResponse(w, r, CodeAuthenticationRequired, "API key required", nil)
+			return
+		}
+
+		if user.ID != mux.Vars(r)["id"] && !isAdmin(user) {
+			LoggerFromContext(r.Context()).Info("User attempted to access another user's resources", zap.String("username", user.Username))
+			errorResponse(w, r, CodeResourceForbidden, "You may only manage your own resources", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin reports whether the user has the admin role
+func isAdmin(user *User) bool {
+	return user.Role == RoleAdmin
+}
+
+// ReconcileBootstrapAdmin makes sure the bootstrap admin user exists, has the
+// admin role and can authenticate with the configured bootstrap key. The
+// bootstrap admin is identified by a stored flag, never by username. It is
+// only created while no admin exists, and a disabled bootstrap admin stays
+// disabled. When the configured key changes, a new key is stored and the
+// previous bootstrap keys are revoked.
+func (a *AuthMiddleware) ReconcileBootstrapAdmin(ctx context.Context) error {
 	if a.config.BootstrapAdminKey == "" {
-		log.Println("⚠️ No bootstrap admin key configured, skipping admin user creation")
+		logger.Warn("No bootstrap admin key configured, skipping admin user creation")
 		return nil
 	}
 
-	log.Println("🔑 Setting up bootstrap admin user...")
+	logger.Info("Reconciling bootstrap admin user")
 
-	// Check if admin user already exists
-	existingUser, err := a.userRepo.GetByUsername("admin")
+	admin, err := a.userRepo.GetBootstrapAdmin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to check for existing admin user: %w", err)
 	}
 
-	if existingUser != nil {
-		// Update the API key if it's different
-		if existingUser.APIKey != a.config.BootstrapAdminKey {
-			log.Println("🔄 Updating admin user API key...")
-			// In a real system, you'd want to update the user's API key
-			// For now, we'll just log that it exists
+	if admin == nil {
+		if admin, err = a.adoptBootstrapAdmin(ctx); err != nil {
+			return err
 		}
-		log.Printf("✅ Admin user already exists: %s", existingUser.Username)
+	}
+
+	if admin == nil {
+		return a.createBootstrapAdmin(ctx)
+	}
+
+	if admin.Role != RoleAdmin {
+		if err := a.userRepo.SetRole(ctx, admin.ID, RoleAdmin); err != nil {
+			return fmt.Errorf("failed to restore admin role: %w", err)
+		}
+		logger.Info("Restored admin role", zap.String("username", admin.Username))
+	}
+
+	if admin.Disabled {
+		logger.Warn("Bootstrap admin user is disabled, the bootstrap key will be rejected", zap.String("username", admin.Username))
+	}
+
+	current, err := a.apiKeyRepo.GetByKey(ctx, a.config.BootstrapAdminKey)
+	if err != nil {
+		return fmt.Errorf("failed to check bootstrap admin key: %w", err)
+	}
+
+	if current != nil {
+		if current.UserID != admin.ID {
+			return fmt.Errorf("bootstrap admin key is already assigned to another user")
+		}
+		if current.Revoked() || current.Expired(time.Now()) {
+			return fmt.Errorf("bootstrap admin key has been revoked or has expired; configure a new key")
+		}
+		logger.Info("Admin user already exists", zap.String("username", admin.Username))
 		return nil
 	}
 
-	// Create admin user
-	adminUser := &User{
-		Username: "admin",
-		APIKey:   a.config.BootstrapAdminKey,
+	// The configured key changed: store the new key before revoking the old
+	// ones so the admin is never left without a working key
+	keys, err := a.apiKeyRepo.ListByUser(ctx, admin.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list admin API keys: %w", err)
+	}
+
+	replacement := &APIKey{
+		UserID: admin.ID,
+		Name:   bootstrapKeyName,
+		Key:    a.config.BootstrapAdminKey,
+		Scopes: []string{ScopeAll},
 	}
+	if err := a.apiKeyRepo.Create(ctx, replacement); err != nil {
+		return fmt.Errorf("failed to store bootstrap admin key: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.Name != bootstrapKeyName || key.Revoked() {
+			continue
+		}
+		if err := a.apiKeyRepo.Revoke(ctx, key.ID); err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to revoke previous bootstrap admin key: %w", err)
+		}
+	}
+
+	logger.Info("Rotated bootstrap admin API key", zap.String("username", admin.Username))
+	return nil
+}
 
-	if err := a.userRepo.Create(adminUser); err != nil {
+// adoptBootstrapAdmin flags the holder of the configured bootstrap key as the
+// bootstrap admin. Deployments from before the flag existed store their
+// bootstrap admin this way. It returns nil if nobody holds the key.
+func (a *AuthMiddleware) adoptBootstrapAdmin(ctx context.Context) (*User, error) {
+	key, err := a.apiKeyRepo.GetByKey(ctx, a.config.BootstrapAdminKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bootstrap admin key: %w", err)
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	owner, err := a.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap admin key owner: %w", err)
+	}
+	if owner == nil {
+		return nil, nil
+	}
+
+	if err := a.userRepo.SetBootstrapAdmin(ctx, owner.ID); err != nil {
+		return nil, fmt.Errorf("failed to flag bootstrap admin: %w", err)
+	}
+
+	logger.Info("Adopted holder of the bootstrap key as bootstrap admin", zap.String("username", owner.Username))
+	return owner, nil
+}
+
+// createBootstrapAdmin creates the bootstrap admin with the configured key,
+// unless an admin already exists
+func (a *AuthMiddleware) createBootstrapAdmin(ctx context.Context) error {
+	users, err := a.userRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, user := range users {
+		if user.Role == RoleAdmin {
+			logger.Warn("An admin user already exists, skipping bootstrap admin creation", zap.String("username", user.Username))
+			return nil
+		}
+	}
+	for _, user := range users {
+		if user.Username == bootstrapAdminUsername {
+			return fmt.Errorf("username %q belongs to an account that is not the bootstrap admin", bootstrapAdminUsername)
+		}
+	}
+
+	admin := &User{
+		Username: bootstrapAdminUsername,
+		Role:     RoleAdmin,
+	}
+	key := &APIKey{
+		Name:   bootstrapKeyName,
+		Key:    a.config.BootstrapAdminKey,
+		Scopes: []string{ScopeAll},
+	}
+
+	if err := a.userRepo.Create(ctx, admin, key); err != nil {
 		return fmt.Errorf("failed to create admin user: %w", err)
 	}
+	if err := a.userRepo.SetBootstrapAdmin(ctx, admin.ID); err != nil {
+		return fmt.Errorf("failed to flag bootstrap admin: %w", err)
+	}
 
-	log.Printf("✅ Created bootstrap admin user with username: %s", adminUser.Username)
+	logger.Info("Created bootstrap admin user", zap.String("username", admin.Username))
 	return nil
 }
 
@@ -216,7 +723,11 @@ func (a *AuthMiddleware) CreateBootstrapUser() error {
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey        contextKey = "user"
+	apiKeyContextKey      contextKey = "api_key"
+	tokenScopesContextKey contextKey = "token_scopes"
+)
 
 // WithUser adds a user to the context
 func WithUser(ctx context.Context, user *User) context.Context {
@@ -229,19 +740,35 @@ func GetUser(ctx context.Context) (*User, bool) {
 	return user, ok
 }
 
-// Helper function for error responses
-func errorResponse(w http.ResponseWriter, status int, message string, _ error) {
-	response := ErrorResponse{
-		Error:   http.StatusText(status),
-		Message: message,
-	}
+// WithAPIKey adds the API key used to authenticate to the context
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// GetAPIKey retrieves the API key used to authenticate from the context
+func GetAPIKey(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*APIKey)
+	return key, ok
+}
+
+// WithTokenScopes adds the scopes granted by a bearer token to the context
+func WithTokenScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, tokenScopesContextKey, scopes)
+}
 
-	jsonResponse(w, status, response)
+// GetTokenScopes retrieves the scopes granted by a bearer token from the
+// context. It reports false when the token did not carry any scope claim.
+func GetTokenScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(tokenScopesContextKey).([]string)
+	return scopes, ok
 }
 
-// jsonResponse helper function
+// jsonResponse helper function. User text is stored as entered, so it is
+// encoded here: encoding/json escapes "<", ">" and "&" as \u003c, \u003e and
+// \u0026, and nosniff stops browsers from rendering the body as HTML.
 func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// errBatchAborted signals that a batch transaction must be rolled back
+var errBatchAborted = errors.New("batch aborted")
+
+// BatchHandler handles HTTP requests for bulk event operations
+type BatchHandler struct {
+	repo          EventRepositoryInterface
+	validator     *RequestValidator
+	maxOperations int
+}
+
+// NewBatchHandler creates a new batch handler
+func NewBatchHandler(repo EventRepositoryInterface, config *Config) *BatchHandler {
+	return &BatchHandler{
+		repo:          repo,
+		validator:     NewRequestValidator(),
+		maxOperations: config.BatchMaxOperations,
+	}
+}
+
+// BatchEvents handles POST /api/events:batch
+func (h *BatchHandler) BatchEvents(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("batch", "events", time.Since(start))
+	}()
+
+	var req BatchRequest
+	if !h.validator.Decode(w, r, &req) {
+		return
+	}
+
+	if len(req.Operations) > h.maxOperations {
+		errorResponse(w, r, CodeBatchTooLarge, fmt.Sprintf("A batch may contain at most %d operations", h.maxOperations), nil)
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = BatchModeAtomic
+	}
+
+	var (
+		response BatchResponse
+		status   int
+	)
+	if req.Mode == BatchModeAtomic {
+		response, status = h.runAtomic(r.Context(), req.Operations)
+	} else {
+		response, status = h.runBestEffort(r.Context(), req.Operations)
+	}
+
+	for _, result := range response.Results {
+		if result.ID != "" {
+			AddAuditTarget(r.Context(), fmt.Sprintf("operations[%d]", result.Index), result.ID)
+		}
+	}
+
+	jsonResponse(w, status, response)
+}
+
+// runAtomic executes all operations in a single transaction, rolling
+// everything back if any operation fails
+func (h *BatchHandler) runAtomic(ctx context.Context, ops []BatchOperation) (BatchResponse, int) {
+	response := BatchResponse{
+		Mode:    BatchModeAtomic,
+		Results: make([]BatchOperationResult, 0, len(ops)),
+	}
+
+	failedAt := -1
+	err := h.repo.WithTransaction(ctx, func(tx EventRepositoryInterface) error {
+		for i, op := range ops {
+			result := h.apply(ctx, tx, i, op)
+			response.Results = append(response.Results, result)
+			if result.Error != "" {
+				failedAt = i
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+
+	if err == nil {
+		response.Succeeded = len(ops)
+		for _, result := range response.Results {
+			recordBatchMetrics(result)
+		}
+		return response, http.StatusOK
+	}
+
+	status := http.StatusUnprocessableEntity
+	reason := "transaction failed"
+	if failedAt >= 0 {
+		if response.Results[failedAt].Status >= http.StatusInternalServerError {
+			status = http.StatusInternalServerError
+		}
+		reason = fmt.Sprintf("operation %d failed", failedAt)
+	} else {
+		// The commit itself failed after every operation succeeded
+		status = http.StatusInternalServerError
+	}
+
+	// Report every other operation as not applied
+	for i := range ops {
+		if i == failedAt {
+			continue
+		}
+		if i < len(response.Results) {
+			response.Results[i].Event = nil
+			response.Results[i].Status = http.StatusFailedDependency
+			response.Results[i].Error = "rolled back: " + reason
+		} else {
+			response.Results = append(response.Results, BatchOperationResult{
+				Index:  i,
+				Op:     ops[i].Op,
+				ID:     ops[i].ID,
+				Status: http.StatusFailedDependency,
+				Error:  "not executed: " + reason,
+			})
+		}
+	}
+
+	response.Failed = len(ops)
+	response.RolledBack = true
+	return response, status
+}
+
+// runBestEffort executes each operation independently so that failures do
+// not affect the remaining operations
+func (h *BatchHandler) runBestEffort(ctx context.Context, ops []BatchOperation) (BatchResponse, int) {
+	response := BatchResponse{
+		Mode:    BatchModeBestEffort,
+		Results: make([]BatchOperationResult, 0, len(ops)),
+	}
+
+	for i, op := range ops {
+		var result BatchOperationResult
+		err := h.repo.WithTransaction(ctx, func(tx EventRepositoryInterface) error {
+			result = h.apply(ctx, tx, i, op)
+			if result.Error != "" {
+				return errBatchAborted
+			}
+			return nil
+		})
+
+		if err != nil && !errors.Is(err, errBatchAborted) {
+			result.Event = nil
+			result.Status = http.StatusInternalServerError
+			result.Error = "Failed to commit operation"
+		}
+
+		if result.Error != "" {
+			response.Failed++
+		} else {
+			response.Succeeded++
+			recordBatchMetrics(result)
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, http.StatusOK
+}
+
+// apply executes a single batch operation against the given repository,
+// recording a revision attributed to the user in ctx
+func (h *BatchHandler) apply(ctx context.Context, repo EventRepositoryInterface, index int, op BatchOperation) BatchOperationResult {
+	result := BatchOperationResult{
+		Index: index,
+		Op:    op.Op,
+		ID:    op.ID,
+	}
+
+	fail := func(status int, message string) BatchOperationResult {
+		result.Status = status
+		result.Error = message
+		return result
+	}
+
+	switch op.Op {
+	case BatchOpCreate:
+		if op.Event == nil {
+			return fail(http.StatusBadRequest, "event is required for create")
+		}
+		event, message := h.buildEvent(*op.Event)
+		if event == nil {
+			return fail(http.StatusBadRequest, message)
+		}
+		if err := repo.Create(ctx, event); err != nil {
+			return fail(http.StatusInternalServerError, "Failed to create event")
+		}
+		if err := repo.RecordRevision(ctx, newRevision(ctx, RevisionActionCreate, event)); err != nil {
+			return fail(http.StatusInternalServerError, "Failed to record event revision")
+		}
+		result.ID = event.ID
+		result.Event = event
+		result.Status = http.StatusCreated
+
+	case BatchOpUpdate:
+		if op.ID == "" {
+			return fail(http.StatusBadRequest, "id is required for update")
+		}
+		if op.Event == nil {
+			return fail(http.StatusBadRequest, "event is required for update")
+		}
+		event, message := h.buildEvent(*op.Event)
+		if event == nil {
+			return fail(http.StatusBadRequest, message)
+		}
+		existing, err := repo.Get(ctx, op.ID)
+		if err != nil {
+			return fail(http.StatusInternalServerError, "Failed to retrieve event")
+		}
+		if existing == nil {
+			return fail(http.StatusNotFound, "Event not found")
+		}
+		event.ID = op.ID
+		event.CreatedAt = existing.CreatedAt
+		if err := repo.Update(ctx, op.ID, event); err != nil {
+			if err == sql.ErrNoRows {
+				return fail(http.StatusNotFound, "Event not found")
+			}
+			return fail(http.StatusInternalServerError, "Failed to update event")
+		}
+		if err := repo.RecordRevision(ctx, newRevision(ctx, RevisionActionUpdate, event)); err != nil {
+			return fail(http.StatusInternalServerError, "Failed to record event revision")
+		}
+		result.Event = event
+		result.Status = http.StatusOK
+
+	case BatchOpDelete:
+		if op.ID == "" {
+			return fail(http.StatusBadRequest, "id is required for delete")
+		}
+		existing, err := repo.Get(ctx, op.ID)
+		if err != nil {
+			return fail(http.StatusInternalServerError, "Failed to retrieve event")
+		}
+		if existing == nil {
+			return fail(http.StatusNotFound, "Event not found")
+		}
+		if err := repo.Delete(ctx, op.ID); err != nil {
+			if err == sql.ErrNoRows {
+				return fail(http.StatusNotFound, "Event not found")
+			}
+			return fail(http.StatusInternalServerError, "Failed to delete event")
+		}
+		deleted := *existing
+		now := time.Now().UTC()
+		deleted.DeletedAt = &now
+		if err := repo.RecordRevision(ctx, newRevision(ctx, RevisionActionDelete, &deleted)); err != nil {
+			return fail(http.StatusInternalServerError, "Failed to record event revision")
+		}
+		result.Status = http.StatusNoContent
+
+	default:
+		return fail(http.StatusBadRequest, fmt.Sprintf("unsupported operation %q", op.Op))
+	}
+
+	return result
+}
+
+// buildEvent validates an event payload and converts it into an Event.
+// On failure it returns nil and a message describing the problem.
+func (h *BatchHandler) buildEvent(req CreateEventRequest) (*Event, string) {
+	if err := h.validator.Struct(req); err != nil {
+		return nil, "validation failed: " + h.validator.describeValidationError(err)
+	}
+
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		return nil, "Invalid start_time format"
+	}
+
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		return nil, "Invalid end_time format"
+	}
+
+	return &Event{
+		Title:       req.Title,
+		Description: req.Description,
+		StartTime:   startTime,
+		EndTime:     endTime,
+	}, ""
+}
+
+// recordBatchMetrics records business metrics for a successful batch operation
+func recordBatchMetrics(result BatchOperationResult) {
+	switch result.Op {
+	case BatchOpCreate:
+		eventsCreatedTotal.Inc()
+	case BatchOpDelete:
+		eventsDeletedTotal.Inc()
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBatchEvents(t *testing.T) {
+	validEvent := func(title string) *CreateEventRequest {
+		return &CreateEventRequest{
+			Title:     title,
+			StartTime: time.Now().Add(time.Hour).Format(time.RFC3339),
+			EndTime:   time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+		}
+	}
+
+	send := func(handler *BatchHandler, req BatchRequest) (*httptest.ResponseRecorder, BatchResponse) {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/events:batch", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.BatchEvents(w, r)
+
+		var response BatchResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("Atomic batch succeeds", func(t *testing.T) {
+		repo := NewMockEventRepository()
+		existing := &Event{Title: "Existing", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)}
+		repo.Create(context.Background(), existing)
+		handler := NewBatchHandler(repo, &Config{BatchMaxOperations: 10})
+
+		w, response := send(handler, BatchRequest{
+			Operations: []BatchOperation{
+				{Op: BatchOpCreate, Event: validEvent("New")},
+				{Op: BatchOpUpdate, ID: existing.ID, Event: validEvent("Renamed")},
+			},
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+		if response.Mode != BatchModeAtomic || response.Succeeded != 2 || response.RolledBack {
+			t.Errorf("Unexpected batch response: %+v", response)
+		}
+		if len(repo.events) != 2 {
+			t.Errorf("Expected 2 events, got %d", len(repo.events))
+		}
+	})
+
+	t.Run("Atomic batch rolls back on failure", func(t *testing.T) {
+		repo := NewMockEventRepository()
+		handler := NewBatchHandler(repo, &Config{BatchMaxOperations: 10})
+
+		w, response := send(handler, BatchRequest{
+			Mode: BatchModeAtomic,
+			Operations: []BatchOperation{
+				{Op: BatchOpCreate, Event: validEvent("New")},
+				{Op: BatchOpDelete, ID: "non-existent-id"},
+				{Op: BatchOpCreate, Event: validEvent("Never")},
+			},
+		})
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected status 422, got %d. Response: %s", w.Code, w.Body.String())
+		}
+		if !response.RolledBack || len(response.Results) != 3 {
+			t.Fatalf("Unexpected batch response: %+v", response)
+		}
+		if response.Results[1].Status != http.StatusNotFound {
+			t.Errorf("Expected failing operation status 404, got %d", response.Results[1].Status)
+		}
+		if response.Results[0].Status != http.StatusFailedDependency || response.Results[2].Status != http.StatusFailedDependency {
+			t.Errorf("Expected other operations to report 424, got %+v", response.Results)
+		}
+		if len(repo.events) != 0 {
+			t.Errorf("Expected rollback to leave no events, got %d", len(repo.events))
+		}
+	})
+
+	t.Run("Best effort batch keeps successful operations", func(t *testing.T) {
+		repo := NewMockEventRepository()
+		handler := NewBatchHandler(repo, &Config{BatchMaxOperations: 10})
+
+		w, response := send(handler, BatchRequest{
+			Mode: BatchModeBestEffort,
+			Operations: []BatchOperation{
+				{Op: BatchOpCreate, Event: validEvent("New")},
+				{Op: BatchOpCreate, Event: &CreateEventRequest{Title: "Missing times"}},
+			},
+		})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+		if response.Succeeded != 1 || response.Failed != 1 {
+			t.Errorf("Expected 1 success and 1 failure, got %+v", response)
+		}
+		if response.Results[1].Status != http.StatusBadRequest {
+			t.Errorf("Expected invalid operation status 400, got %d", response.Results[1].Status)
+		}
+		if len(repo.events) != 1 {
+			t.Errorf("Expected 1 event, got %d", len(repo.events))
+		}
+	})
+
+	t.Run("Too many operations", func(t *testing.T) {
+		handler := NewBatchHandler(NewMockEventRepository(), &Config{BatchMaxOperations: 1})
+
+		w, _ := send(handler, BatchRequest{
+			Operations: []BatchOperation{
+				{Op: BatchOpCreate, Event: validEvent("One")},
+				{Op: BatchOpCreate, Event: validEvent("Two")},
+			},
+		})
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Reasons recorded for failed authentication attempts
+const (
+	authFailureMissingCredentials = "missing_credentials"
+	authFailureInvalidKey         = "invalid_key"
+	authFailureRevokedKey         = "revoked_key"
+	authFailureExpiredKey         = "expired_key"
+	authFailureInvalidToken       = "invalid_token"
+	authFailureUnknownUser        = "unknown_user"
+	authFailureDisabledUser       = "disabled_user"
+	authFailureLockedOut          = "locked_out"
+)
+
+const (
+	// maxAuthFailureDelay caps the progressive delay applied to failed attempts
+	maxAuthFailureDelay = 5 * time.Second
+
+	// AuthFailurePruneInterval is how often stale failure records are dropped
+	AuthFailurePruneInterval = time.Minute
+
+	// authLockoutCacheTTL is how long a lockout lookup is reused for the same
+	// IP, so that authenticated requests do not each query the store.
+	// Lockouts recorded or cleared by other instances apply here within it.
+	authLockoutCacheTTL = 2 * time.Second
+)
+
+// AuthLockout describes the failed authentication attempts of a client IP
+type AuthLockout struct {
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// ListAuthLockoutsResponse represents the response for listing lockouts
+type ListAuthLockoutsResponse struct {
+	Lockouts []AuthLockout `json:"lockouts"`
+	Count    int           `json:"count"`
+}
+
+// Authentication failure store backends
+const (
+	AuthFailureStoreMemory   = "memory"
+	AuthFailureStorePostgres = "postgres"
+)
+
+// AuthFailureStore keeps the failed authentication attempts of client IPs
+type AuthFailureStore interface {
+	// Get returns the failure record of ip, or nil if there is none
+	Get(ctx context.Context, ip string) (*AuthLockout, error)
+	// RecordFailure counts a failure of ip at now, restarting the count if
+	// the previous failure was at or before staleBefore, and locks the
+	// client out until lockedUntil once maxFailures is reached
+	RecordFailure(ctx context.Context, ip string, now, staleBefore, lockedUntil time.Time, maxFailures int) (*AuthLockout, error)
+	// List returns every failure record, most recent first
+	List(ctx context.Context) ([]AuthLockout, error)
+	// Clear removes the failure record of ip, reporting whether one existed
+	Clear(ctx context.Context, ip string) (bool, error)
+	// Prune drops records that are not locked out at now and whose last
+	// failure was at or before staleBefore
+	Prune(ctx context.Context, now, staleBefore time.Time) error
+}
+
+// AuthFailureTracker counts failed authentication attempts per client IP and
+// locks out clients that exceed the allowed number of failures. Successful
+// attempts do not reset the count, so that a client holding one valid
+// credential cannot interleave it with guesses to avoid a lockout.
+type AuthFailureTracker struct {
+	store       AuthFailureStore
+	maxFailures int
+	lockout     time.Duration
+	baseDelay   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedLockout
+}
+
+// cachedLockout is a recent lockout lookup of an IP. A zero lockedUntil
+// means the IP was not locked out.
+type cachedLockout struct {
+	lockedUntil time.Time
+	expires     time.Time
+}
+
+// NewAuthFailureTracker creates a tracker from configuration
+func NewAuthFailureTracker(store AuthFailureStore, config *Config) *AuthFailureTracker {
+	return &AuthFailureTracker{
+		store:       store,
+		maxFailures: config.AuthMaxFailures,
+		lockout:     config.AuthLockoutDuration,
+		baseDelay:   config.AuthFailureDelay,
+		cache:       make(map[string]cachedLockout),
+	}
+}
+
+// LockedUntil returns when the lockout of ip ends, if it is locked out.
+// Results are cached for authLockoutCacheTTL.
+func (t *AuthFailureTracker) LockedUntil(ctx context.Context, ip string, now time.Time) (time.Time, bool, error) {
+	t.mu.Lock()
+	cached, ok := t.cache[ip]
+	t.mu.Unlock()
+
+	if !ok || !now.Before(cached.expires) {
+		client, err := t.store.Get(ctx, ip)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		cached = t.remember(ip, client, now)
+	}
+
+	if !now.Before(cached.lockedUntil) {
+		return time.Time{}, false, nil
+	}
+	return cached.lockedUntil, true, nil
+}
+
+// remember caches the lockout state of ip from its failure record
+func (t *AuthFailureTracker) remember(ip string, client *AuthLockout, now time.Time) cachedLockout {
+	cached := cachedLockout{expires: now.Add(authLockoutCacheTTL)}
+	if client != nil && client.LockedUntil != nil {
+		cached.lockedUntil = *client.LockedUntil
+	}
+
+	t.mu.Lock()
+	t.cache[ip] = cached
+	t.mu.Unlock()
+	return cached
+}
+
+// RecordFailure registers a failed attempt and returns how long the response
+// should be delayed. Failures older than the lockout duration are forgotten.
+func (t *AuthFailureTracker) RecordFailure(ctx context.Context, ip string, now time.Time) (time.Duration, error) {
+	client, err := t.store.RecordFailure(ctx, ip, now, now.Add(-t.lockout), now.Add(t.lockout), t.maxFailures)
+	if err != nil {
+		return t.baseDelay, err
+	}
+	t.remember(ip, client, now)
+
+	if t.maxFailures > 0 && client.Failures >= t.maxFailures {
+		LoggerFromContext(ctx).Warn("Locked out client after failed authentication attempts",
+			zap.String("ip", ip),
+			zap.Int("failures", client.Failures),
+		)
+	}
+
+	// Double the delay with every failure
+	delay := t.baseDelay
+	for i := 1; i < client.Failures && delay < maxAuthFailureDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxAuthFailureDelay), nil
+}
+
+// List returns the clients with recorded failures, most recent first
+func (t *AuthFailureTracker) List(ctx context.Context) ([]AuthLockout, error) {
+	return t.store.List(ctx)
+}
+
+// Clear removes the failure record of ip, reporting whether one existed
+func (t *AuthFailureTracker) Clear(ctx context.Context, ip string) (bool, error) {
+	cleared, err := t.store.Clear(ctx, ip)
+
+	t.mu.Lock()
+	delete(t.cache, ip)
+	t.mu.Unlock()
+
+	return cleared, err
+}
+
+// Prune drops records whose failures and lockout have expired, along with
+// expired cache entries
+func (t *AuthFailureTracker) Prune(ctx context.Context, now time.Time) error {
+	t.mu.Lock()
+	for ip, cached := range t.cache {
+		if !now.Before(cached.expires) {
+			delete(t.cache, ip)
+		}
+	}
+	t.mu.Unlock()
+
+	return t.store.Prune(ctx, now, now.Add(-t.lockout))
+}
+
+// MemoryAuthFailureStore keeps failure records per process
+type MemoryAuthFailureStore struct {
+	mu      sync.Mutex
+	clients map[string]*AuthLockout
+}
+
+// NewMemoryAuthFailureStore creates an empty in-memory store
+func NewMemoryAuthFailureStore() *MemoryAuthFailureStore {
+	return &MemoryAuthFailureStore{clients: make(map[string]*AuthLockout)}
+}
+
+func (s *MemoryAuthFailureStore) Get(ctx context.Context, ip string) (*AuthLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[ip]
+	if !ok {
+		return nil, nil
+	}
+	record := *client
+	return &record, nil
+}
+
+func (s *MemoryAuthFailureStore) RecordFailure(ctx context.Context, ip string, now, staleBefore, lockedUntil time.Time, maxFailures int) (*AuthLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[ip]
+	if !ok || !client.LastFailure.After(staleBefore) {
+		client = &AuthLockout{IP: ip}
+		s.clients[ip] = client
+	}
+
+	client.Failures++
+	client.LastFailure = now
+	if maxFailures > 0 && client.Failures >= maxFailures {
+		client.LockedUntil = &lockedUntil
+	}
+
+	record := *client
+	return &record, nil
+}
+
+func (s *MemoryAuthFailureStore) List(ctx context.Context) ([]AuthLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lockouts := make([]AuthLockout, 0, len(s.clients))
+	for _, client := range s.clients {
+		lockouts = append(lockouts, *client)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFailure.After(lockouts[j].LastFailure)
+	})
+	return lockouts, nil
+}
+
+func (s *MemoryAuthFailureStore) Clear(ctx context.Context, ip string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.clients[ip]
+	delete(s.clients, ip)
+	return ok, nil
+}
+
+func (s *MemoryAuthFailureStore) Prune(ctx context.Context, now, staleBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ip, client := range s.clients {
+		locked := client.LockedUntil != nil && now.Before(*client.LockedUntil)
+		if !locked && !client.LastFailure.After(staleBefore) {
+			delete(s.clients, ip)
+		}
+	}
+	return nil
+}
+
+// PostgresAuthFailureStore keeps failure records in the auth_failures table,
+// shared by every instance of the service
+type PostgresAuthFailureStore struct {
+	db *DB
+}
+
+// NewPostgresAuthFailureStore creates a store backed by the auth_failures table
+func NewPostgresAuthFailureStore(db *DB) *PostgresAuthFailureStore {
+	return &PostgresAuthFailureStore{db: db}
+}
+
+const authLockoutColumns = "ip, failures, last_failure, locked_until"
+
+func scanAuthLockout(row rowScanner, lockout *AuthLockout) error {
+	return row.Scan(&lockout.IP, &lockout.Failures, &lockout.LastFailure, &lockout.LockedUntil)
+}
+
+func (s *PostgresAuthFailureStore) Get(ctx context.Context, ip string) (*AuthLockout, error) {
+	ctx, span := startSpan(ctx, "PostgresAuthFailureStore.Get")
+	defer span.End()
+
+	query := `SELECT ` + authLockoutColumns + ` FROM auth_failures WHERE ip = $1`
+
+	var lockout AuthLockout
+	err := scanAuthLockout(s.db.QueryRowContext(ctx, query, ip), &lockout)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth failures: %w", err)
+	}
+	return &lockout, nil
+}
+
+// RecordFailure increments the client's counter in a single statement so
+// that concurrent failures across instances are all counted
+func (s *PostgresAuthFailureStore) RecordFailure(ctx context.Context, ip string, now, staleBefore, lockedUntil time.Time, maxFailures int) (*AuthLockout, error) {
+	ctx, span := startSpan(ctx, "PostgresAuthFailureStore.RecordFailure")
+	defer span.End()
+
+	query := `
+		INSERT INTO auth_failures (ip, failures, last_failure, locked_until)
+		VALUES ($1, 1, $2, CASE WHEN 1 >= $5 THEN $4::timestamptz END)
+		ON CONFLICT (ip) DO UPDATE SET
+			failures = CASE WHEN auth_failures.last_failure <= $3 THEN 1 ELSE auth_failures.failures + 1 END,
+			last_failure = EXCLUDED.last_failure,
+			locked_until = CASE
+				WHEN (CASE WHEN auth_failures.last_failure <= $3 THEN 1 ELSE auth_failures.failures + 1 END) >= $5 THEN $4::timestamptz
+				WHEN auth_failures.last_failure <= $3 THEN NULL
+				ELSE auth_failures.locked_until
+			END
+		RETURNING ` + authLockoutColumns
+
+	var lockout AuthLockout
+	err := scanAuthLockout(s.db.QueryRowContext(ctx, query, ip, now, staleBefore, lockedUntil, maxFailures), &lockout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record auth failure: %w", err)
+	}
+	return &lockout, nil
+}
+
+func (s *PostgresAuthFailureStore) List(ctx context.Context) ([]AuthLockout, error) {
+	ctx, span := startSpan(ctx, "PostgresAuthFailureStore.List")
+	defer span.End()
+
+	query := `SELECT ` + authLockoutColumns + ` FROM auth_failures ORDER BY last_failure DESC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query auth failures: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := []AuthLockout{}
+	for rows.Next() {
+		var lockout AuthLockout
+		if err := scanAuthLockout(rows, &lockout); err != nil {
+			return nil, fmt.Errorf("failed to scan auth failures: %w", err)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return lockouts, nil
+}
+
+func (s *PostgresAuthFailureStore) Clear(ctx context.Context, ip string) (bool, error) {
+	ctx, span := startSpan(ctx, "PostgresAuthFailureStore.Clear")
+	defer span.End()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM auth_failures WHERE ip = $1`, ip)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear auth failures: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (s *PostgresAuthFailureStore) Prune(ctx context.Context, now, staleBefore time.Time) error {
+	ctx, span := startSpan(ctx, "PostgresAuthFailureStore.Prune")
+	defer span.End()
+
+	query := `
+		DELETE FROM auth_failures
+		WHERE last_failure <= $2 AND (locked_until IS NULL OR locked_until <= $1)
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, now, staleBefore); err != nil {
+		return fmt.Errorf("failed to delete expired auth failures: %w", err)
+	}
+	return nil
+}
+
+// rejectLockedOut writes a 429 response if the client IP is locked out
+func (a *AuthMiddleware) rejectLockedOut(w http.ResponseWriter, r *http.Request) bool {
+	lockedUntil, locked, err := a.failures.LockedUntil(r.Context(), clientIP(r), time.Now())
+	if err != nil {
+		// Credentials are still checked, so fail open rather than reject everyone
+		LoggerFromContext(r.Context()).Error("Failed to check authentication lockout", zap.Error(err))
+	}
+	if !locked {
+		return false
+	}
+
+	authFailuresTotal.WithLabelValues(authFailureLockedOut).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(time.Until(lockedUntil)), 1)))
+	errorResponse(w, r, CodeAuthLockedOut, "Too many failed authentication attempts, retry later", nil)
+	return true
+}
+
+// authFailed records a failed authentication attempt and writes the error
+// response. Failures caused by invalid credentials count towards a lockout
+// and are answered after a progressive delay.
+func (a *AuthMiddleware) authFailed(w http.ResponseWriter, r *http.Request, reason string, code ErrorCode, message string) {
+	authFailuresTotal.WithLabelValues(reason).Inc()
+
+	if code.Status() == http.StatusUnauthorized && reason != authFailureMissingCredentials {
+		delay, err := a.failures.RecordFailure(r.Context(), clientIP(r), time.Now())
+		if err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to record authentication failure", zap.Error(err))
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	errorResponse(w, r, code, message, nil)
+}
+
+// AuthLockoutHandler handles HTTP requests for managing authentication lockouts
+type AuthLockoutHandler struct {
+	failures *AuthFailureTracker
+}
+
+// NewAuthLockoutHandler creates a new lockout handler
+func NewAuthLockoutHandler(failures *AuthFailureTracker) *AuthLockoutHandler {
+	return &AuthLockoutHandler{failures: failures}
+}
+
+// ListLockouts handles GET /api/auth/lockouts
+func (h *AuthLockoutHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.failures.List(r.Context())
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve lockouts", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, ListAuthLockoutsResponse{
+		Lockouts: lockouts,
+		Count:    len(lockouts),
+	})
+}
+
+// ClearLockout handles DELETE /api/auth/lockouts/{ip}
+func (h *AuthLockoutHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ip := vars["ip"]
+
+	cleared, err := h.failures.Clear(r.Context(), ip)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to clear lockout", err)
+		return
+	}
+	if !cleared {
+		errorResponse(w, r, CodeLockoutNotFound, "No failed attempts recorded for this IP", nil)
+		return
+	}
+
+	LoggerFromContext(r.Context()).Info("Cleared authentication lockout", zap.String("ip", ip))
+	w.WriteHeader(http.StatusNoContent)
+}
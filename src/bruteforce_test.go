@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAuthFailureTracker(t *testing.T) {
+	tracker := NewAuthFailureTracker(NewMemoryAuthFailureStore(), &Config{
+		AuthMaxFailures:     3,
+		AuthLockoutDuration: time.Minute,
+		AuthFailureDelay:    time.Second,
+	})
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("Delay doubles with every failure", func(t *testing.T) {
+		for i, expected := range []time.Duration{time.Second, 2 * time.Second} {
+			if delay, _ := tracker.RecordFailure(ctx, "10.0.0.1", now); delay != expected {
+				t.Errorf("Failure %d: expected delay %s, got %s", i+1, expected, delay)
+			}
+		}
+		if _, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now); locked {
+			t.Error("Expected no lockout below the failure limit")
+		}
+	})
+
+	t.Run("Lock out at the failure limit", func(t *testing.T) {
+		tracker.RecordFailure(ctx, "10.0.0.1", now)
+		lockedUntil, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now)
+		if !locked || !lockedUntil.Equal(now.Add(time.Minute)) {
+			t.Fatalf("Expected lockout until %s, got %s (locked %t)", now.Add(time.Minute), lockedUntil, locked)
+		}
+		if _, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now.Add(time.Minute)); locked {
+			t.Error("Expected lockout to end after the lockout duration")
+		}
+	})
+
+	t.Run("Delay is capped", func(t *testing.T) {
+		var delay time.Duration
+		for i := 0; i < 10; i++ {
+			delay, _ = tracker.RecordFailure(ctx, "10.0.0.2", now)
+		}
+		if delay != maxAuthFailureDelay {
+			t.Errorf("Expected delay capped at %s, got %s", maxAuthFailureDelay, delay)
+		}
+	})
+
+	t.Run("Old failures are forgotten", func(t *testing.T) {
+		tracker.RecordFailure(ctx, "10.0.0.3", now)
+		tracker.RecordFailure(ctx, "10.0.0.3", now.Add(2*time.Minute))
+		for _, lockout := range mustListLockouts(t, tracker) {
+			if lockout.IP == "10.0.0.3" && lockout.Failures != 1 {
+				t.Errorf("Expected failure count to restart, got %d", lockout.Failures)
+			}
+		}
+	})
+
+	t.Run("Prune drops expired records", func(t *testing.T) {
+		tracker.Prune(ctx, now.Add(30*time.Second))
+		if n := len(mustListLockouts(t, tracker)); n != 3 {
+			t.Errorf("Expected 3 tracked clients, got %d", n)
+		}
+		tracker.Prune(ctx, now.Add(3*time.Minute))
+		if n := len(mustListLockouts(t, tracker)); n != 0 {
+			t.Errorf("Expected all records to be pruned, got %d", n)
+		}
+	})
+}
+
+// countingAuthFailureStore counts lockout lookups made against the store
+type countingAuthFailureStore struct {
+	AuthFailureStore
+	gets int
+}
+
+func (s *countingAuthFailureStore) Get(ctx context.Context, ip string) (*AuthLockout, error) {
+	s.gets++
+	return s.AuthFailureStore.Get(ctx, ip)
+}
+
+func TestAuthFailureTrackerCache(t *testing.T) {
+	store := &countingAuthFailureStore{AuthFailureStore: NewMemoryAuthFailureStore()}
+	tracker := NewAuthFailureTracker(store, &Config{AuthMaxFailures: 1, AuthLockoutDuration: time.Minute})
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now); locked {
+			t.Fatal("Expected no lockout before any failure")
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("Expected repeated lookups to be cached, got %d store lookups", store.gets)
+	}
+
+	tracker.RecordFailure(ctx, "10.0.0.1", now)
+	if _, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now); !locked || store.gets != 1 {
+		t.Errorf("Expected a local failure to update the cache, got locked %t after %d lookups", locked, store.gets)
+	}
+
+	tracker.Clear(ctx, "10.0.0.1")
+	if _, locked, _ := tracker.LockedUntil(ctx, "10.0.0.1", now); locked || store.gets != 2 {
+		t.Errorf("Expected clearing to drop the cached lockout, got locked %t after %d lookups", locked, store.gets)
+	}
+
+	tracker.LockedUntil(ctx, "10.0.0.1", now.Add(authLockoutCacheTTL))
+	if store.gets != 3 {
+		t.Errorf("Expected the lookup to be repeated after the cache TTL, got %d lookups", store.gets)
+	}
+}
+
+func TestBruteForceProtection(t *testing.T) {
+	config := &Config{
+		BootstrapAdminKey:   "test-admin-key-123",
+		APIKeyHeader:        "X-API-Key",
+		Environment:         "test",
+		AuthMaxFailures:     3,
+		AuthLockoutDuration: time.Minute,
+	}
+
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config)
+	if err := authMiddleware.ReconcileBootstrapAdmin(context.Background()); err != nil {
+		t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+	}
+	lockoutHandler := NewAuthLockoutHandler(authMiddleware.failures)
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(authMiddleware.RequireAuthentication)
+	api.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+
+	lockouts := api.PathPrefix("/auth/lockouts").Subrouter()
+	lockouts.Use(authMiddleware.RequireScope(ScopeUsersAdmin))
+	lockouts.Use(authMiddleware.RequireAdmin)
+	lockouts.HandleFunc("", lockoutHandler.ListLockouts).Methods("GET")
+	lockouts.HandleFunc("/{ip}", lockoutHandler.ClearLockout).Methods("DELETE")
+
+	serve := func(method, path, ip, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	const attacker = "203.0.113.7"
+
+	t.Run("Missing credentials do not count", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if w := serve("GET", "/api/events", attacker, ""); w.Code != http.StatusUnauthorized {
+				t.Fatalf("Expected status 401, got %d", w.Code)
+			}
+		}
+		if n := len(mustListLockouts(t, authMiddleware.failures)); n != 0 {
+			t.Errorf("Expected no tracked failures, got %d", n)
+		}
+	})
+
+	t.Run("Repeated invalid keys lock out the client", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if w := serve("GET", "/api/events", attacker, "wrong-key"); w.Code != http.StatusUnauthorized {
+				t.Fatalf("Attempt %d: expected status 401, got %d", i+1, w.Code)
+			}
+		}
+
+		w := serve("GET", "/api/events", attacker, config.BootstrapAdminKey)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429 while locked out, got %d", w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header")
+		}
+
+		if w := serve("GET", "/api/events", "198.51.100.1", config.BootstrapAdminKey); w.Code != http.StatusOK {
+			t.Errorf("Expected other clients to be unaffected, got %d", w.Code)
+		}
+	})
+
+	t.Run("Admin lists and clears lockouts", func(t *testing.T) {
+		w := serve("GET", "/api/auth/lockouts", "198.51.100.1", config.BootstrapAdminKey)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var response ListAuthLockoutsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Count != 1 || response.Lockouts[0].IP != attacker || response.Lockouts[0].LockedUntil == nil {
+			t.Fatalf("Unexpected lockouts: %+v", response)
+		}
+
+		if w := serve("DELETE", "/api/auth/lockouts/"+attacker, "198.51.100.1", config.BootstrapAdminKey); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d", w.Code)
+		}
+		if w := serve("DELETE", "/api/auth/lockouts/"+attacker, "198.51.100.1", config.BootstrapAdminKey); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for cleared IP, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", attacker, config.BootstrapAdminKey); w.Code != http.StatusOK {
+			t.Errorf("Expected cleared client to authenticate, got %d", w.Code)
+		}
+	})
+
+	t.Run("Success does not reset the failure count", func(t *testing.T) {
+		// A valid key between guesses must not keep the client from being locked out
+		for i := 0; i < 3; i++ {
+			serve("GET", "/api/events", attacker, "wrong-key")
+			if i < 2 {
+				if w := serve("GET", "/api/events", attacker, config.BootstrapAdminKey); w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200 before the lockout, got %d", w.Code)
+				}
+			}
+		}
+		if w := serve("GET", "/api/events", attacker, config.BootstrapAdminKey); w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429 after interleaved failures, got %d", w.Code)
+		}
+	})
+}
+
+func mustListLockouts(t *testing.T, tracker *AuthFailureTracker) []AuthLockout {
+	t.Helper()
+	lockouts, err := tracker.List(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list lockouts: %v", err)
+	}
+	return lockouts
+}
+
+func TestAuthFailureDelayZeroFromSecrets(t *testing.T) {
+	t.Setenv("DOPPLER_SECRETS_JSON", `{"TF_VAR_auth_failure_delay":"0s"}`)
+	t.Setenv("AUTH_FAILURE_DELAY", "1s")
+
+	c := &Config{}
+	if err := c.loadFromDopplerSecrets(); err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	c.loadFromEnv()
+
+	if c.AuthFailureDelay != 0 {
+		t.Errorf("Expected an explicit failure delay of 0 to be kept, got %v", c.AuthFailureDelay)
+	}
+}
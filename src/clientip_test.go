@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 10.0.1.0/24, 10.0.2.7 ,2001:db8::/32")
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	if len(proxies) != 3 {
+		t.Fatalf("Expected 3 trusted proxies, got %v", proxies)
+	}
+	if proxies[1].String() != "10.0.2.7/32" {
+		t.Errorf("Expected a single address to be a /32, got %s", proxies[1])
+	}
+
+	if proxies, err := ParseTrustedProxies(""); err != nil || len(proxies) != 0 {
+		t.Errorf("Expected no trusted proxies, got %v, %v", proxies, err)
+	}
+	if _, err := ParseTrustedProxies("10.0.1.0/24,load-balancer"); err == nil {
+		t.Error("Expected an invalid entry to be rejected")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, _ := ParseTrustedProxies("10.0.1.0/24")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "Direct connection", remoteAddr: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "Untrusted peer cannot forge its address", remoteAddr: "203.0.113.7:4000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "Trusted proxy", remoteAddr: "10.0.1.5:4000", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "Client-supplied entries are ignored", remoteAddr: "10.0.1.5:4000", forwarded: []string{"198.51.100.1, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "Chained trusted proxies", remoteAddr: "10.0.1.5:4000", forwarded: []string{"203.0.113.7, 10.0.1.9"}, want: "203.0.113.7"},
+		{name: "Repeated headers", remoteAddr: "10.0.1.5:4000", forwarded: []string{"198.51.100.1", "203.0.113.7"}, want: "203.0.113.7"},
+		{name: "Malformed entry", remoteAddr: "10.0.1.5:4000", forwarded: []string{"203.0.113.7, not-an-ip"}, want: "10.0.1.5"},
+		{name: "Trusted proxy without header", remoteAddr: "10.0.1.5:4000", want: "10.0.1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/version", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			var got string
+			handler := ClientIPMiddleware(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("Expected client IP %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	// Without the middleware only the connection's peer is known
+	req := httptest.NewRequest("GET", "/version", nil)
+	req.RemoteAddr = "10.0.1.5:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := clientIP(req); got != "10.0.1.5" {
+		t.Errorf("Expected the peer address, got %s", got)
+	}
+}
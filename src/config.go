@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Config holds all application configuration
@@ -30,6 +33,75 @@ type Config struct {
 	DopplerProject     string
 	DopplerEnvironment string
 	DopplerConfig      string
+
+	// Idempotency configuration
+	IdempotencyKeyTTL          time.Duration
+	IdempotencyCleanupInterval time.Duration
+
+	// Batch configuration
+	BatchMaxOperations int
+
+	// Request body configuration
+	MaxRequestBodyBytes int
+
+	// Trash configuration
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	// API key configuration
+	APIKeyRotationGracePeriod time.Duration
+	// apiKeyRotationGracePeriodSet records that the grace period came from
+	// the secrets, so an explicit 0 is not mistaken for an unset value
+	apiKeyRotationGracePeriodSet bool
+
+	// JWT bearer authentication configuration
+	JWTJWKSURL         string
+	JWTJWKSFile        string
+	JWTIssuer          string
+	JWTAudience        string
+	JWTUsernameClaim   string
+	JWTAutoProvision   bool
+	JWTDefaultRole     string
+	JWTJWKSRefreshRate time.Duration
+
+	// Rate limiting configuration
+	RateLimitEnabled   bool
+	RateLimitStore     string
+	RateLimitAdmin     string
+	RateLimitMember    string
+	RateLimitService   string
+	RateLimitAnonymous string
+
+	// Proxies whose X-Forwarded-For header is trusted, as comma-separated
+	// addresses or CIDR ranges
+	TrustedProxies string
+
+	// Brute-force protection configuration
+	AuthMaxFailures     int
+	AuthLockoutDuration time.Duration
+	AuthFailureDelay    time.Duration
+	AuthFailureStore    string
+	// authFailureDelaySet records that the delay came from the secrets, so
+	// an explicit 0 is not mistaken for an unset value
+	authFailureDelaySet bool
+
+	// Metrics configuration
+	MetricsCacheTTL time.Duration
+	// metricsCacheTTLSet records that the TTL came from the secrets, so an
+	// explicit 0 is not mistaken for an unset value
+	metricsCacheTTLSet bool
+
+	// Tracing configuration
+	TracingExporter     string
+	TracingOTLPEndpoint string
+	TracingSampleRatio  float64
+	// tracingSampleRatioSet records that the ratio came from the secrets, so
+	// an explicit 0 is not mistaken for an unset value
+	tracingSampleRatioSet bool
+
+	// Logging configuration
+	LogLevel  string
+	LogFormat string
 }
 
 // LoadConfig loads configuration from environment variables and Doppler secrets
@@ -38,7 +110,7 @@ func LoadConfig() (*Config, error) {
 
 	// Load from DOPPLER_SECRETS_JSON if available
 	if err := config.loadFromDopplerSecrets(); err != nil {
-		log.Printf("Warning: Failed to load Doppler secrets: %v", err)
+		logger.Warn("Failed to load Doppler secrets", zap.Error(err))
 	}
 
 	// Load from individual environment variables (fallback)
@@ -62,7 +134,7 @@ func (c *Config) loadFromDopplerSecrets() error {
 		return fmt.Errorf("DOPPLER_SECRETS_JSON not found")
 	}
 
-	log.Println("🔐 Loading configuration from Doppler secrets...")
+	logger.Info("Loading configuration from Doppler secrets")
 
 	var secrets map[string]interface{}
 	if err := json.Unmarshal([]byte(secretsJSON), &secrets); err != nil {
@@ -90,13 +162,71 @@ func (c *Config) loadFromDopplerSecrets() error {
 	c.DopplerEnvironment = getStringFromSecrets(secrets, "TF_VAR_doppler_environment", "")
 	c.DopplerConfig = getStringFromSecrets(secrets, "TF_VAR_doppler_config", "")
 
-	log.Printf("✅ Loaded configuration for environment: %s", c.Environment)
+	// Idempotency configuration
+	c.IdempotencyKeyTTL = getDurationFromSecrets(secrets, "TF_VAR_idempotency_key_ttl", 24*time.Hour)
+	c.IdempotencyCleanupInterval = getDurationFromSecrets(secrets, "TF_VAR_idempotency_cleanup_interval", time.Hour)
+
+	// Batch configuration
+	c.BatchMaxOperations = getIntFromSecrets(secrets, "TF_VAR_batch_max_operations", 1000)
+
+	// Request body configuration
+	c.MaxRequestBodyBytes = getIntFromSecrets(secrets, "TF_VAR_max_request_body_bytes", 2<<20)
+
+	// Trash configuration
+	c.TrashRetention = getDurationFromSecrets(secrets, "TF_VAR_trash_retention", 30*24*time.Hour)
+	c.TrashPurgeInterval = getDurationFromSecrets(secrets, "TF_VAR_trash_purge_interval", time.Hour)
+
+	// API key configuration
+	c.APIKeyRotationGracePeriod = getDurationFromSecrets(secrets, "TF_VAR_api_key_rotation_grace_period", 24*time.Hour)
+	c.apiKeyRotationGracePeriodSet = true
+
+	// JWT bearer authentication configuration
+	c.JWTJWKSURL = getStringFromSecrets(secrets, "TF_VAR_jwt_jwks_url", "")
+	c.JWTJWKSFile = getStringFromSecrets(secrets, "TF_VAR_jwt_jwks_file", "")
+	c.JWTIssuer = getStringFromSecrets(secrets, "TF_VAR_jwt_issuer", "")
+	c.JWTAudience = getStringFromSecrets(secrets, "TF_VAR_jwt_audience", "")
+	c.JWTUsernameClaim = getStringFromSecrets(secrets, "TF_VAR_jwt_username_claim", "sub")
+	c.JWTAutoProvision = getBoolFromSecrets(secrets, "TF_VAR_jwt_auto_provision", false)
+	c.JWTDefaultRole = getStringFromSecrets(secrets, "TF_VAR_jwt_default_role", RoleMember)
+	c.JWTJWKSRefreshRate = getDurationFromSecrets(secrets, "TF_VAR_jwt_jwks_refresh_rate", time.Hour)
+
+	// Rate limiting configuration
+	c.RateLimitEnabled = getBoolFromSecrets(secrets, "TF_VAR_rate_limit_enabled", true)
+	c.RateLimitStore = getStringFromSecrets(secrets, "TF_VAR_rate_limit_store", RateLimitStoreMemory)
+	c.RateLimitAdmin = getStringFromSecrets(secrets, "TF_VAR_rate_limit_admin", "1200/1m")
+	c.RateLimitMember = getStringFromSecrets(secrets, "TF_VAR_rate_limit_member", "600/1m")
+	c.RateLimitService = getStringFromSecrets(secrets, "TF_VAR_rate_limit_service", "3000/1m")
+	c.RateLimitAnonymous = getStringFromSecrets(secrets, "TF_VAR_rate_limit_anonymous", "60/1m")
+	c.TrustedProxies = getStringFromSecrets(secrets, "TF_VAR_trusted_proxies", "")
+
+	// Brute-force protection configuration
+	c.AuthMaxFailures = getIntFromSecrets(secrets, "TF_VAR_auth_max_failures", 10)
+	c.AuthLockoutDuration = getDurationFromSecrets(secrets, "TF_VAR_auth_lockout_duration", 15*time.Minute)
+	c.AuthFailureDelay = getDurationFromSecrets(secrets, "TF_VAR_auth_failure_delay", 250*time.Millisecond)
+	c.authFailureDelaySet = true
+	c.AuthFailureStore = getStringFromSecrets(secrets, "TF_VAR_auth_failure_store", AuthFailureStorePostgres)
+
+	// Metrics configuration
+	c.MetricsCacheTTL = getDurationFromSecrets(secrets, "TF_VAR_metrics_cache_ttl", 30*time.Second)
+	c.metricsCacheTTLSet = true
+
+	// Tracing configuration
+	c.TracingExporter = getStringFromSecrets(secrets, "TF_VAR_tracing_exporter", TracingExporterNone)
+	c.TracingOTLPEndpoint = getStringFromSecrets(secrets, "TF_VAR_tracing_otlp_endpoint", "")
+	c.TracingSampleRatio = getFloatFromSecrets(secrets, "TF_VAR_tracing_sample_ratio", 1)
+	c.tracingSampleRatioSet = true
+
+	// Logging configuration
+	c.LogLevel = getStringFromSecrets(secrets, "TF_VAR_log_level", defaultLogLevel(c.Debug))
+	c.LogFormat = getStringFromSecrets(secrets, "TF_VAR_log_format", LogFormatJSON)
+
+	logger.Info("Loaded configuration from Doppler secrets", zap.String("environment", c.Environment))
 	return nil
 }
 
 // loadFromEnv loads configuration from individual environment variables (fallback)
 func (c *Config) loadFromEnv() {
-	log.Println("🔧 Loading configuration from environment variables...")
+	logger.Info("Loading configuration from environment variables")
 
 	// Only override if not already set by Doppler
 	if c.Host == "" {
@@ -139,6 +269,129 @@ func (c *Config) loadFromEnv() {
 	if c.Environment == "" {
 		c.Environment = getEnv("ENVIRONMENT", "development")
 	}
+
+	// Idempotency configuration
+	if c.IdempotencyKeyTTL == 0 {
+		c.IdempotencyKeyTTL = getDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	}
+	if c.IdempotencyCleanupInterval == 0 {
+		c.IdempotencyCleanupInterval = getDurationEnv("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour)
+	}
+
+	// Batch configuration
+	if c.BatchMaxOperations == 0 {
+		c.BatchMaxOperations = getIntEnv("BATCH_MAX_OPERATIONS", 1000)
+	}
+
+	// Request body configuration
+	if c.MaxRequestBodyBytes == 0 {
+		c.MaxRequestBodyBytes = getIntEnv("MAX_REQUEST_BODY_BYTES", 2<<20)
+	}
+
+	// Trash configuration
+	if c.TrashRetention == 0 {
+		c.TrashRetention = getDurationEnv("TRASH_RETENTION", 30*24*time.Hour)
+	}
+	if c.TrashPurgeInterval == 0 {
+		c.TrashPurgeInterval = getDurationEnv("TRASH_PURGE_INTERVAL", time.Hour)
+	}
+
+	// API key configuration
+	if !c.apiKeyRotationGracePeriodSet {
+		c.APIKeyRotationGracePeriod = getDurationEnv("API_KEY_ROTATION_GRACE_PERIOD", 24*time.Hour)
+	}
+
+	// JWT bearer authentication configuration
+	if c.JWTJWKSURL == "" {
+		c.JWTJWKSURL = getEnv("JWT_JWKS_URL", "")
+	}
+	if c.JWTJWKSFile == "" {
+		c.JWTJWKSFile = getEnv("JWT_JWKS_FILE", "")
+	}
+	if c.JWTIssuer == "" {
+		c.JWTIssuer = getEnv("JWT_ISSUER", "")
+	}
+	if c.JWTAudience == "" {
+		c.JWTAudience = getEnv("JWT_AUDIENCE", "")
+	}
+	if c.JWTUsernameClaim == "" {
+		c.JWTUsernameClaim = getEnv("JWT_USERNAME_CLAIM", "sub")
+	}
+	if !c.JWTAutoProvision {
+		c.JWTAutoProvision = getEnv("JWT_AUTO_PROVISION", "false") == "true"
+	}
+	if c.JWTDefaultRole == "" {
+		c.JWTDefaultRole = getEnv("JWT_DEFAULT_ROLE", RoleMember)
+	}
+	if c.JWTJWKSRefreshRate == 0 {
+		c.JWTJWKSRefreshRate = getDurationEnv("JWT_JWKS_REFRESH_RATE", time.Hour)
+	}
+
+	// Rate limiting configuration
+	if c.RateLimitStore == "" {
+		// Rate limiting defaults to on, so only read the flag when the
+		// settings were not loaded from secrets
+		c.RateLimitEnabled = getEnv("RATE_LIMIT_ENABLED", "true") == "true"
+		c.RateLimitStore = getEnv("RATE_LIMIT_STORE", RateLimitStoreMemory)
+	}
+	if c.RateLimitAdmin == "" {
+		c.RateLimitAdmin = getEnv("RATE_LIMIT_ADMIN", "1200/1m")
+	}
+	if c.RateLimitMember == "" {
+		c.RateLimitMember = getEnv("RATE_LIMIT_MEMBER", "600/1m")
+	}
+	if c.RateLimitService == "" {
+		c.RateLimitService = getEnv("RATE_LIMIT_SERVICE", "3000/1m")
+	}
+	if c.RateLimitAnonymous == "" {
+		c.RateLimitAnonymous = getEnv("RATE_LIMIT_ANONYMOUS", "60/1m")
+	}
+	if c.TrustedProxies == "" {
+		c.TrustedProxies = getEnv("TRUSTED_PROXIES", "")
+	}
+	if c.AuthMaxFailures == 0 {
+		c.AuthMaxFailures = getIntEnv("AUTH_MAX_FAILURES", 10)
+	}
+	if c.AuthLockoutDuration == 0 {
+		c.AuthLockoutDuration = getDurationEnv("AUTH_LOCKOUT_DURATION", 15*time.Minute)
+	}
+	if !c.authFailureDelaySet {
+		c.AuthFailureDelay = getDurationEnv("AUTH_FAILURE_DELAY", 250*time.Millisecond)
+	}
+	if c.AuthFailureStore == "" {
+		c.AuthFailureStore = getEnv("AUTH_FAILURE_STORE", AuthFailureStorePostgres)
+	}
+	if !c.metricsCacheTTLSet {
+		c.MetricsCacheTTL = getDurationEnv("METRICS_CACHE_TTL", 30*time.Second)
+	}
+	if c.TracingExporter == "" {
+		c.TracingExporter = getEnv("TRACING_EXPORTER", TracingExporterNone)
+	}
+	if c.TracingOTLPEndpoint == "" {
+		c.TracingOTLPEndpoint = getEnv("TRACING_OTLP_ENDPOINT", "")
+	}
+	if !c.tracingSampleRatioSet {
+		c.TracingSampleRatio = getFloatEnv("TRACING_SAMPLE_RATIO", 1)
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = getEnv("LOG_LEVEL", defaultLogLevel(c.Debug))
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = getEnv("LOG_FORMAT", LogFormatJSON)
+	}
+}
+
+// defaultLogLevel logs at debug level in debug mode and at info otherwise
+func defaultLogLevel(debug bool) string {
+	if debug {
+		return zapcore.DebugLevel.String()
+	}
+	return zapcore.InfoLevel.String()
+}
+
+// JWTEnabled reports whether bearer token authentication is configured
+func (c *Config) JWTEnabled() bool {
+	return c.JWTJWKSURL != "" || c.JWTJWKSFile != ""
 }
 
 // validate ensures all required configuration is present
@@ -152,32 +405,140 @@ func (c *Config) validate() error {
 	if c.DBName == "" {
 		return fmt.Errorf("database name is required")
 	}
+	if c.IdempotencyKeyTTL <= 0 {
+		return fmt.Errorf("idempotency key TTL must be positive")
+	}
+	if c.IdempotencyCleanupInterval <= 0 {
+		return fmt.Errorf("idempotency cleanup interval must be positive")
+	}
+	if c.BatchMaxOperations <= 0 {
+		return fmt.Errorf("batch max operations must be positive")
+	}
+	if c.MaxRequestBodyBytes <= 0 {
+		return fmt.Errorf("max request body bytes must be positive")
+	}
+	if c.TrashRetention <= 0 {
+		return fmt.Errorf("trash retention must be positive")
+	}
+	if c.TrashPurgeInterval <= 0 {
+		return fmt.Errorf("trash purge interval must be positive")
+	}
+	if c.APIKeyRotationGracePeriod < 0 {
+		return fmt.Errorf("API key rotation grace period must not be negative")
+	}
+	if c.JWTJWKSURL != "" && c.JWTJWKSFile != "" {
+		return fmt.Errorf("only one of JWT JWKS URL and JWT JWKS file may be set")
+	}
+	if c.JWTEnabled() && c.JWTIssuer == "" {
+		return fmt.Errorf("JWT issuer is required when JWT authentication is enabled")
+	}
+	if c.JWTEnabled() && c.JWTAudience == "" {
+		return fmt.Errorf("JWT audience is required when JWT authentication is enabled")
+	}
+	if c.JWTDefaultRole != RoleAdmin && c.JWTDefaultRole != RoleMember && c.JWTDefaultRole != RoleService {
+		return fmt.Errorf("JWT default role must be one of admin, member or service")
+	}
+	if c.JWTJWKSRefreshRate <= 0 {
+		return fmt.Errorf("JWT JWKS refresh rate must be positive")
+	}
+	if c.RateLimitStore != RateLimitStoreMemory && c.RateLimitStore != RateLimitStorePostgres {
+		return fmt.Errorf("rate limit store must be memory or postgres")
+	}
+	for _, limit := range []string{c.RateLimitAdmin, c.RateLimitMember, c.RateLimitService, c.RateLimitAnonymous} {
+		if _, err := ParseRateLimit(limit); err != nil {
+			return err
+		}
+	}
+	if _, err := ParseTrustedProxies(c.TrustedProxies); err != nil {
+		return err
+	}
+	if c.AuthMaxFailures <= 0 {
+		return fmt.Errorf("auth max failures must be positive")
+	}
+	if c.AuthLockoutDuration <= 0 {
+		return fmt.Errorf("auth lockout duration must be positive")
+	}
+	if c.AuthFailureDelay < 0 {
+		return fmt.Errorf("auth failure delay must not be negative")
+	}
+	if c.AuthFailureStore != AuthFailureStoreMemory && c.AuthFailureStore != AuthFailureStorePostgres {
+		return fmt.Errorf("auth failure store must be memory or postgres")
+	}
+	if c.MetricsCacheTTL < 0 {
+		return fmt.Errorf("metrics cache TTL must not be negative")
+	}
+	if c.TracingExporter != TracingExporterNone && c.TracingExporter != TracingExporterOTLP && c.TracingExporter != TracingExporterStdout {
+		return fmt.Errorf("tracing exporter must be none, otlp or stdout")
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("tracing sample ratio must be between 0 and 1")
+	}
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log level must be debug, info, warn or error")
+	}
+	if c.LogFormat != LogFormatJSON && c.LogFormat != LogFormatConsole {
+		return fmt.Errorf("log format must be json or console")
+	}
 
 	return nil
 }
 
 // logConfig logs the current configuration (without sensitive data)
 func (c *Config) logConfig() {
-	log.Println("📋 Application Configuration:")
-	log.Printf("  Environment: %s", c.Environment)
-	log.Printf("  Host: %s", c.Host)
-	log.Printf("  Port: %s", c.Port)
-	log.Printf("  Database Host: %s", c.DBHost)
-	log.Printf("  Database Name: %s", c.DBName)
-	log.Printf("  Database User: %s", c.DBUser)
-	log.Printf("  Database SSL Mode: %s", c.DBSSLMode)
-	log.Printf("  Debug Mode: %t", c.Debug)
-	log.Printf("  API Key Header: %s", c.APIKeyHeader)
-
-	if c.BootstrapAdminKey != "" {
-		log.Printf("  Bootstrap Admin Key: %s***", c.BootstrapAdminKey[:8])
+	fields := []zap.Field{
+		zap.String("environment", c.Environment),
+		zap.String("host", c.Host),
+		zap.String("port", c.Port),
+		zap.String("db_host", c.DBHost),
+		zap.String("db_name", c.DBName),
+		zap.String("db_user", c.DBUser),
+		zap.String("db_ssl_mode", c.DBSSLMode),
+		zap.Bool("debug", c.Debug),
+		zap.String("log_level", c.LogLevel),
+		zap.String("log_format", c.LogFormat),
+		zap.String("api_key_header", c.APIKeyHeader),
+		zap.Duration("idempotency_key_ttl", c.IdempotencyKeyTTL),
+		zap.Int("batch_max_operations", c.BatchMaxOperations),
+		zap.Int("max_request_body_bytes", c.MaxRequestBodyBytes),
+		zap.Duration("trash_retention", c.TrashRetention),
+		zap.Duration("api_key_rotation_grace_period", c.APIKeyRotationGracePeriod),
+		zap.Bool("rate_limit_enabled", c.RateLimitEnabled),
+		zap.String("trusted_proxies", c.TrustedProxies),
+		zap.Int("auth_max_failures", c.AuthMaxFailures),
+		zap.Duration("auth_lockout_duration", c.AuthLockoutDuration),
+		zap.String("auth_failure_store", c.AuthFailureStore),
+		zap.Duration("metrics_cache_ttl", c.MetricsCacheTTL),
+		zap.String("tracing_exporter", c.TracingExporter),
+		zap.Bool("bootstrap_admin_key", c.BootstrapAdminKey != ""),
+	}
+	if c.RateLimitEnabled {
+		fields = append(fields,
+			zap.String("rate_limit_admin", c.RateLimitAdmin),
+			zap.String("rate_limit_member", c.RateLimitMember),
+			zap.String("rate_limit_service", c.RateLimitService),
+			zap.String("rate_limit_anonymous", c.RateLimitAnonymous),
+			zap.String("rate_limit_store", c.RateLimitStore),
+		)
+	}
+	if c.TracingExporter != TracingExporterNone {
+		fields = append(fields, zap.Float64("tracing_sample_ratio", c.TracingSampleRatio))
+	}
+	if c.JWTEnabled() {
+		fields = append(fields,
+			zap.String("jwt_issuer", c.JWTIssuer),
+			zap.String("jwt_audience", c.JWTAudience),
+			zap.Bool("jwt_auto_provision", c.JWTAutoProvision),
+		)
 	}
-
 	if c.DopplerProject != "" {
-		log.Printf("  Doppler Project: %s", c.DopplerProject)
-		log.Printf("  Doppler Environment: %s", c.DopplerEnvironment)
-		log.Printf("  Doppler Config: %s", c.DopplerConfig)
+		fields = append(fields,
+			zap.String("doppler_project", c.DopplerProject),
+			zap.String("doppler_environment", c.DopplerEnvironment),
+			zap.String("doppler_config", c.DopplerConfig),
+		)
 	}
+
+	logger.Info("Application configuration", fields...)
 }
 
 // Helper functions
@@ -202,9 +563,69 @@ func getBoolFromSecrets(secrets map[string]interface{}, key string, defaultValue
 	return defaultValue
 }
 
+func getIntFromSecrets(secrets map[string]interface{}, key string, defaultValue int) int {
+	if val, ok := secrets[key]; ok {
+		if str, ok := val.(string); ok {
+			if parsed, err := strconv.Atoi(str); err == nil {
+				return parsed
+			}
+		}
+	}
+	return defaultValue
+}
+
+func getDurationFromSecrets(secrets map[string]interface{}, key string, defaultValue time.Duration) time.Duration {
+	if val, ok := secrets[key]; ok {
+		if str, ok := val.(string); ok {
+			if parsed, err := time.ParseDuration(str); err == nil {
+				return parsed
+			}
+		}
+	}
+	return defaultValue
+}
+
+func getFloatFromSecrets(secrets map[string]interface{}, key string, defaultValue float64) float64 {
+	if val, ok := secrets[key]; ok {
+		if str, ok := val.(string); ok {
+			if parsed, err := strconv.ParseFloat(str, 64); err == nil {
+				return parsed
+			}
+		}
+	}
+	return defaultValue
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // DB wraps the SQL database connection
@@ -16,14 +21,29 @@ type DB struct {
 
 // NewDB creates a new database connection using the provided configuration
 func NewDB(config *Config) (*DB, error) {
-	log.Printf("🔌 Connecting to database: %s@%s:%s/%s", config.DBUser, config.DBHost, config.DBPort, config.DBName)
+	logger.Info("Connecting to database",
+		zap.String("user", config.DBUser),
+		zap.String("host", config.DBHost),
+		zap.String("port", config.DBPort),
+		zap.String("name", config.DBName),
+	)
 
 	// Build connection string
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		config.DBHost, config.DBPort, config.DBUser, config.DBPassword, config.DBName, config.DBSSLMode)
 
-	// Open database connection
-	db, err := sql.Open("postgres", connStr)
+	// Open database connection, tracing every statement run within a traced
+	// request or repository call
+	db, err := otelsql.Open("postgres", connStr,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -48,3 +68,27 @@ func NewDB(config *Config) (*DB, error) {
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
+
+// InTransaction runs fn inside a database transaction, committing if fn
+// succeeds and rolling back otherwise
+func (db *DB) InTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			LoggerFromContext(ctx).Warn("Failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// errUnsupportedMediaType is returned for request bodies that are not JSON
+	errUnsupportedMediaType = errors.New("unsupported media type")
+	// errEmptyBody is returned when a required request body is missing
+	errEmptyBody = errors.New("empty request body")
+	// errTrailingData is returned when a request body holds more than one
+	// JSON value
+	errTrailingData = errors.New("request body contains more than one JSON value")
+)
+
+// BodyLimitMiddleware rejects request bodies larger than maxBytes. Reading
+// past the limit fails with *http.MaxBytesError, which bodyErrorResponse
+// reports as 413 Request Entity Too Large.
+func BodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasJSONContentType reports whether the request declares a JSON body
+func hasJSONContentType(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// decodeJSONBody strictly decodes the request body into dst: the body must
+// be declared as application/json and hold exactly one JSON value with no
+// fields dst does not define. An empty body is accepted, leaving dst
+// unchanged, when optional is set. A value of the wrong type is returned as
+// *json.UnmarshalTypeError after the rest of the body has been decoded.
+func decodeJSONBody(r *http.Request, dst interface{}, optional bool) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		if optional {
+			return nil
+		}
+		return errEmptyBody
+	}
+	if !hasJSONContentType(r) {
+		return errUnsupportedMediaType
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		if optional {
+			return nil
+		}
+		return errEmptyBody
+	case err != nil && !errors.As(err, &typeErr):
+		return err
+	}
+
+	if extra := decoder.Decode(&json.RawMessage{}); !errors.Is(extra, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(extra, &maxBytesErr) {
+			return extra
+		}
+		return errTrailingData
+	}
+	return err
+}
+
+// bodyErrorResponse writes the response for a request body that could not be
+// read or decoded
+func bodyErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		timeErr     *time.ParseError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		errorResponse(w, r, CodeRequestBodyTooLarge, fmt.Sprintf("Request body must be at most %d bytes", maxBytesErr.Limit), nil)
+	case errors.Is(err, errUnsupportedMediaType):
+		errorResponse(w, r, CodeUnsupportedMediaType, fmt.Sprintf("Content-Type must be application/json, got %q", r.Header.Get("Content-Type")), nil)
+	case errors.Is(err, errEmptyBody):
+		errorResponse(w, r, CodeInvalidRequestBody, "Request body is empty", nil)
+	case errors.Is(err, errTrailingData):
+		errorResponse(w, r, CodeInvalidRequestBody, "Request body must contain a single JSON value", nil)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		errorResponse(w, r, CodeInvalidRequestBody, "Request body ends before the JSON value is complete", nil)
+	case errors.As(err, &syntaxErr):
+		errorResponse(w, r, CodeInvalidRequestBody, fmt.Sprintf("Malformed JSON at byte %d: %s", syntaxErr.Offset, syntaxErr.Error()), nil)
+	case errors.As(err, &typeErr):
+		errorResponse(w, r, CodeInvalidRequestBody, fmt.Sprintf("Value at byte %d must be a JSON %s", typeErr.Offset, jsonTypeName(typeErr.Type)), nil)
+	case errors.As(err, &timeErr):
+		errorResponse(w, r, CodeInvalidRequestBody, fmt.Sprintf("Timestamp %q must be in RFC 3339 format", timeErr.Value), nil)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json reports unknown fields with a plain error
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		errorResponse(w, r, CodeInvalidRequestBody, "Unknown field "+field, nil)
+	default:
+		errorResponse(w, r, CodeInvalidRequestBody, "Invalid request body", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const validEventBody = `{"title":"Planning","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`
+
+func TestDecodeStrictRequestBody(t *testing.T) {
+	handler, _ := setupEventTest(t)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        ErrorCode
+		detail      string
+	}{
+		{name: "Valid body", contentType: "application/json", body: validEventBody, status: http.StatusCreated},
+		{name: "Charset parameter", contentType: "application/json; charset=utf-8", body: validEventBody, status: http.StatusCreated},
+		{name: "Missing Content-Type", body: validEventBody, status: http.StatusUnsupportedMediaType, code: CodeUnsupportedMediaType, detail: `got ""`},
+		{name: "Form body", contentType: "application/x-www-form-urlencoded", body: "title=Planning", status: http.StatusUnsupportedMediaType, code: CodeUnsupportedMediaType},
+		{name: "Empty body", contentType: "application/json", status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: "Request body is empty"},
+		{name: "Unknown field", contentType: "application/json", body: `{"title":"Planning","colour":"red","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`, status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: `Unknown field "colour"`},
+		{name: "Trailing value", contentType: "application/json", body: validEventBody + `{}`, status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: "single JSON value"},
+		{name: "Trailing garbage", contentType: "application/json", body: validEventBody + ` garbage`, status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: "single JSON value"},
+		{name: "Malformed JSON", contentType: "application/json", body: `{"title":}`, status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: "Malformed JSON at byte 10"},
+		{name: "Truncated JSON", contentType: "application/json", body: `{"title":"Planning"`, status: http.StatusBadRequest, code: CodeInvalidRequestBody, detail: "ends before"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/events", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			handler.CreateEvent(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.code == "" {
+				return
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, response.Code)
+			}
+			if !strings.Contains(response.Message, tt.detail) {
+				t.Errorf("Expected message to contain %q, got %q", tt.detail, response.Message)
+			}
+		})
+	}
+}
+
+func TestDecodeOptionalRequestBody(t *testing.T) {
+	v := NewRequestValidator()
+
+	for _, body := range []string{"", "{}"} {
+		req := httptest.NewRequest("POST", "/api/users/1/keys/1:rotate", strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		var dst RotateAPIKeyRequest
+		w := httptest.NewRecorder()
+		if !v.DecodeOptional(w, req, &dst) {
+			t.Errorf("Expected body %q to be accepted, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/users/1/keys/1:rotate", strings.NewReader(`{"expires_at":"next week"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	if v.DecodeOptional(w, req, &RotateAPIKeyRequest{}) || w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid timestamp to be rejected with 400, got %d", w.Code)
+	}
+}
+
+func TestRouterRejectsOversizedAndNonJSONBodies(t *testing.T) {
+	r, _ := setupSpecTest(t)
+
+	oversized := `{"title":"Planning","description":"` + strings.Repeat("a", 64<<10) + `","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		idempotent  bool
+		code        ErrorCode
+	}{
+		{name: "Oversized body", contentType: "application/json", body: oversized, code: CodeRequestBodyTooLarge},
+		{name: "Oversized idempotent body", contentType: "application/json", body: oversized, idempotent: true, code: CodeRequestBodyTooLarge},
+		{name: "Plain text body", contentType: "text/plain", body: validEventBody, code: CodeUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/events", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("X-API-Key", "test-admin-key-123")
+			req.Header.Set("Accept", ProblemContentType)
+			if tt.idempotent {
+				req.Header.Set(IdempotencyKeyHeader, "oversized")
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.code.Status() {
+				t.Fatalf("Expected status %d, got %d: %s", tt.code.Status(), w.Code, w.Body.String())
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.code || problem.Detail == "" {
+				t.Errorf("Unexpected problem: %+v", problem)
+			}
+		})
+	}
+}
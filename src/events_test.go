@@ -2,12 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,18 +18,20 @@ import (
 
 // MockEventRepository implements EventRepositoryInterface for testing
 type MockEventRepository struct {
-	events  map[string]*Event
-	counter int
+	events    map[string]*Event
+	revisions map[string][]EventRevision
+	counter   int
 }
 
 func NewMockEventRepository() *MockEventRepository {
 	return &MockEventRepository{
-		events:  make(map[string]*Event),
-		counter: 0,
+		events:    make(map[string]*Event),
+		revisions: make(map[string][]EventRevision),
+		counter:   0,
 	}
 }
 
-func (m *MockEventRepository) Create(event *Event) error {
+func (m *MockEventRepository) Create(ctx context.Context, event *Event) error {
 	if event.ID == "" {
 		m.counter++
 		event.ID = fmt.Sprintf("mock-id-%d", m.counter)
@@ -42,33 +46,39 @@ func (m *MockEventRepository) Create(event *Event) error {
 	return nil
 }
 
-func (m *MockEventRepository) Get(id string) (*Event, error) {
-	if event, ok := m.events[id]; ok {
+func (m *MockEventRepository) Get(ctx context.Context, id string) (*Event, error) {
+	if event, ok := m.events[id]; ok && event.DeletedAt == nil {
 		return event, nil
 	}
 	return nil, nil
 }
 
-func (m *MockEventRepository) Update(id string, event *Event) error {
-	if _, ok := m.events[id]; !ok {
+func (m *MockEventRepository) Update(ctx context.Context, id string, event *Event) error {
+	if existing, ok := m.events[id]; !ok || existing.DeletedAt != nil {
 		return nil
 	}
 	m.events[id] = event
 	return nil
 }
 
-func (m *MockEventRepository) Delete(id string) error {
-	if _, ok := m.events[id]; !ok {
+func (m *MockEventRepository) Delete(ctx context.Context, id string) error {
+	event, ok := m.events[id]
+	if !ok || event.DeletedAt != nil {
 		return sql.ErrNoRows
 	}
-	delete(m.events, id)
+	deleted := *event
+	now := time.Now()
+	deleted.DeletedAt = &now
+	m.events[id] = &deleted
 	return nil
 }
 
-func (m *MockEventRepository) List() ([]Event, error) {
+func (m *MockEventRepository) List(ctx context.Context) ([]Event, error) {
 	events := make([]Event, 0, len(m.events))
 	for _, event := range m.events {
-		events = append(events, *event)
+		if event.DeletedAt == nil {
+			events = append(events, *event)
+		}
 	}
 	// Sort by start time to match the real repository behavior
 	sort.Slice(events, func(i, j int) bool {
@@ -77,7 +87,86 @@ func (m *MockEventRepository) List() ([]Event, error) {
 	return events, nil
 }
 
-func (m *MockEventRepository) Ping() error {
+func (m *MockEventRepository) Each(ctx context.Context, fn func(*Event) error) error {
+	events, _ := m.List(ctx)
+	for i := range events {
+		if err := fn(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockEventRepository) ListTrash(ctx context.Context) ([]Event, error) {
+	events := make([]Event, 0)
+	for _, event := range m.events {
+		if event.DeletedAt != nil {
+			events = append(events, *event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MockEventRepository) Restore(ctx context.Context, id string) error {
+	event, ok := m.events[id]
+	if !ok || event.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	restored := *event
+	restored.DeletedAt = nil
+	m.events[id] = &restored
+	return nil
+}
+
+func (m *MockEventRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for id, event := range m.events {
+		if event.DeletedAt != nil && !event.DeletedAt.After(before) {
+			delete(m.events, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MockEventRepository) RecordRevision(ctx context.Context, revision *EventRevision) error {
+	revision.Revision = len(m.revisions[revision.EventID]) + 1
+	revision.CreatedAt = time.Now()
+	m.revisions[revision.EventID] = append(m.revisions[revision.EventID], *revision)
+	return nil
+}
+
+func (m *MockEventRepository) ListRevisions(ctx context.Context, eventID string) ([]EventRevision, error) {
+	return m.revisions[eventID], nil
+}
+
+func (m *MockEventRepository) GetRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error) {
+	revisions := m.revisions[eventID]
+	if revision < 1 || revision > len(revisions) {
+		return nil, nil
+	}
+	return &revisions[revision-1], nil
+}
+
+func (m *MockEventRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockEventRepository) WithTransaction(ctx context.Context, fn func(tx EventRepositoryInterface) error) error {
+	// Restore the previous state on error to mimic a rollback
+	snapshot := make(map[string]*Event, len(m.events))
+	for id, event := range m.events {
+		snapshot[id] = event
+	}
+	revisions := make(map[string][]EventRevision, len(m.revisions))
+	for id, list := range m.revisions {
+		revisions[id] = list
+	}
+	if err := fn(m); err != nil {
+		m.events = snapshot
+		m.revisions = revisions
+		return err
+	}
 	return nil
 }
 
@@ -96,13 +185,13 @@ func (m *MockAuthMiddleware) RequireAPIKey(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		apiKey := r.Header.Get(m.config.APIKeyHeader)
 		if apiKey == "" {
-			errorResponse(w, http.StatusUnauthorized, "API key required", nil)
+			errorResponse(w, r, CodeAuthenticationRequired, "API key required", nil)
 			return
 		}
 
 		// For testing, accept the bootstrap key
 		if apiKey != m.config.BootstrapAdminKey {
-			errorResponse(w, http.StatusUnauthorized, "Invalid API key", nil)
+			errorResponse(w, r, CodeAPIKeyInvalid, "Invalid API key", nil)
 			return
 		}
 
@@ -110,7 +199,6 @@ func (m *MockAuthMiddleware) RequireAPIKey(next http.Handler) http.Handler {
 		testUser := &User{
 			ID:       "test-user",
 			Username: "testuser",
-			APIKey:   apiKey,
 		}
 		r = r.WithContext(WithUser(r.Context(), testUser))
 		next.ServeHTTP(w, r)
@@ -156,6 +244,17 @@ func TestEventsCreateEvent(t *testing.T) {
 			apiKey:         "test-admin-key-123",
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name: "Special characters stored as entered",
+			event: CreateEventRequest{
+				Title:       `Q&A: <Design> "Review" & Jo's 1:1`,
+				Description: stringPtr("Bring <notes> & questions"),
+				StartTime:   time.Now().Add(time.Hour).Format(time.RFC3339),
+				EndTime:     time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+			},
+			apiKey:         "test-admin-key-123",
+			expectedStatus: http.StatusCreated,
+		},
 		{
 			name: "No authentication",
 			event: CreateEventRequest{
@@ -220,6 +319,14 @@ func TestEventsCreateEvent(t *testing.T) {
 			}
 
 			if tt.expectedStatus == http.StatusCreated {
+				// Stored text is encoded for output rather than at write time
+				if strings.ContainsAny(w.Body.String(), "<>") {
+					t.Errorf("Expected HTML characters to be escaped in JSON output: %s", w.Body.String())
+				}
+				if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+					t.Error("Expected X-Content-Type-Options: nosniff")
+				}
+
 				var event Event
 				if err := json.NewDecoder(w.Body).Decode(&event); err != nil {
 					t.Fatalf("Failed to decode response: %v", err)
@@ -234,6 +341,9 @@ func TestEventsCreateEvent(t *testing.T) {
 				if event.Title != tt.event.Title {
 					t.Errorf("Expected title %s, got %s", tt.event.Title, event.Title)
 				}
+				if stored := mockRepo.events[event.ID]; stored == nil || stored.Title != tt.event.Title {
+					t.Errorf("Expected title to be stored as %q, got %+v", tt.event.Title, stored)
+				}
 			}
 		})
 	}
@@ -633,6 +743,77 @@ func TestEventsListEvents(t *testing.T) {
 	})
 }
 
+func TestEventsTrashAndRestore(t *testing.T) {
+	repo := NewMockEventRepository()
+	handler := NewEventHandler(repo)
+
+	event := &Event{
+		Title:     "Event to Restore",
+		StartTime: time.Now().Add(time.Hour),
+		EndTime:   time.Now().Add(2 * time.Hour),
+	}
+	repo.Create(context.Background(), event)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/events/{id}:restore", handler.RestoreEvent).Methods("POST")
+	router.HandleFunc("/api/events/{id}", handler.DeleteEvent).Methods("DELETE")
+	router.HandleFunc("/api/trash", handler.ListTrash).Methods("GET")
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := serve("DELETE", "/api/events/"+event.ID); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	t.Run("Deleted event is in the trash", func(t *testing.T) {
+		w := serve("GET", "/api/trash")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var response ListEventsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Count != 1 || response.Events[0].DeletedAt == nil {
+			t.Errorf("Expected 1 deleted event in the trash, got %+v", response)
+		}
+	})
+
+	t.Run("Restore deleted event", func(t *testing.T) {
+		w := serve("POST", "/api/events/"+event.ID+":restore")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var restored Event
+		if err := json.Unmarshal(w.Body.Bytes(), &restored); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if restored.ID != event.ID || restored.DeletedAt != nil {
+			t.Errorf("Expected restored event %s, got %+v", event.ID, restored)
+		}
+	})
+
+	t.Run("Restore event that is not in the trash", func(t *testing.T) {
+		if w := serve("POST", "/api/events/"+event.ID+":restore"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("Purge removes old trash", func(t *testing.T) {
+		repo.Delete(context.Background(), event.ID)
+		purged, _ := repo.PurgeDeleted(context.Background(), time.Now().Add(time.Minute))
+		if purged != 1 {
+			t.Errorf("Expected 1 purged event, got %d", purged)
+		}
+	})
+}
+
 // Helper function
 func stringPtr(s string) *string {
 	return &s
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Media types of event listings besides JSON
+const (
+	CSVContentType    = "text/csv"
+	NDJSONContentType = "application/x-ndjson"
+)
+
+// streamFlushInterval is the number of streamed rows sent between flushes
+const streamFlushInterval = 100
+
+// streamMaxDuration bounds how long a streamed listing may run. The database
+// cursor holds a pooled connection until the stream ends, so a slow client
+// must not be able to keep it indefinitely.
+const streamMaxDuration = 5 * time.Minute
+
+// listFormat is a representation of an event listing
+type listFormat struct {
+	name      string // value of the format query parameter
+	mediaType string
+}
+
+// listFormats are the supported listing formats, in order of preference
+// when the client accepts several equally
+var listFormats = []listFormat{
+	{name: "json", mediaType: "application/json"},
+	{name: "csv", mediaType: CSVContentType},
+	{name: "ndjson", mediaType: NDJSONContentType},
+}
+
+// csvEventHeader names the columns of CSV event listings
+var csvEventHeader = []string{"id", "title", "description", "start_time", "end_time", "created_at", "updated_at"}
+
+// mediaRange is one entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges listed in the request's Accept
+// headers. Malformed entries are skipped.
+func parseAccept(r *http.Request) []mediaRange {
+	var ranges []mediaRange
+	for _, accept := range r.Header.Values("Accept") {
+		for _, entry := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(value, 64); err != nil {
+					continue
+				}
+			}
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality the client gives mediaType, taken from
+// the most specific range that matches it, or 0 when none does
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, mr := range ranges {
+		s := -1
+		switch mr.mediaType {
+		case mediaType:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			quality, specificity = mr.q, s
+		}
+	}
+	return quality
+}
+
+// negotiateListFormat picks the listing format from the format query
+// parameter or, failing that, the Accept header. JSON is used when the
+// client accepts none of the formats.
+func negotiateListFormat(r *http.Request) (listFormat, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, format := range listFormats {
+			if format.name == name {
+				return format, true
+			}
+		}
+		return listFormat{}, false
+	}
+
+	ranges := parseAccept(r)
+	best, bestQuality := listFormats[0], 0.0
+	for _, format := range listFormats {
+		if q := acceptQuality(ranges, format.mediaType); q > bestQuality {
+			best, bestQuality = format, q
+		}
+	}
+	return best, true
+}
+
+// eventStreamWriter writes events one at a time in a streamed format
+type eventStreamWriter interface {
+	WriteEvent(event *Event) error
+	Flush() error
+}
+
+// csvEventWriter writes events as CSV rows
+type csvEventWriter struct {
+	w *csv.Writer
+}
+
+func newCSVEventWriter(w http.ResponseWriter) (*csvEventWriter, error) {
+	cw := &csvEventWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(csvEventHeader)
+}
+
+func (cw *csvEventWriter) WriteEvent(event *Event) error {
+	description := ""
+	if event.Description != nil {
+		description = *event.Description
+	}
+	return cw.w.Write([]string{
+		event.ID,
+		csvSafe(event.Title),
+		csvSafe(description),
+		event.StartTime.UTC().Format(time.RFC3339),
+		event.EndTime.UTC().Format(time.RFC3339),
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (cw *csvEventWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// csvSafe stops spreadsheet applications from evaluating user text as a
+// formula by prefixing cells that start with a formula character with "'"
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ndjsonEventWriter writes events as JSON Lines
+type ndjsonEventWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonEventWriter) WriteEvent(event *Event) error {
+	return nw.enc.Encode(event)
+}
+
+func (nw *ndjsonEventWriter) Flush() error {
+	return nil
+}
+
+// streamEvents writes every event from the repository to w in format,
+// flushing every streamFlushInterval rows. Headers are only sent once the
+// first row is available, so that a failing query can still be reported as
+// an error response. The server write timeout is extended after every flush,
+// and the whole stream is cut off after streamMaxDuration.
+func (h *EventHandler) streamEvents(w http.ResponseWriter, r *http.Request, format listFormat) {
+	var out eventStreamWriter
+	controller := http.NewResponseController(w)
+
+	ctx, cancel := context.WithTimeout(r.Context(), streamMaxDuration)
+	defer cancel()
+
+	extendDeadline := func() error {
+		err := controller.SetWriteDeadline(time.Now().Add(serverWriteTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	start := func() error {
+		w.Header().Set("Content-Type", format.mediaType+"; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if format.mediaType == CSVContentType {
+			w.Header().Set("Content-Disposition", `attachment; filename="events.csv"`)
+		}
+		if err := extendDeadline(); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+
+		if format.mediaType == CSVContentType {
+			cw, err := newCSVEventWriter(w)
+			out = cw
+			return err
+		}
+		out = &ndjsonEventWriter{enc: json.NewEncoder(w)}
+		return nil
+	}
+
+	rows := 0
+	err := h.repo.Each(ctx, func(event *Event) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := out.WriteEvent(event); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%streamFlushInterval == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			if err := extendDeadline(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil && out == nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve events", err)
+		return
+	}
+	if err != nil {
+		// The status has been sent, so the client sees a truncated listing
+		LoggerFromContext(r.Context()).Error("Failed to stream events", zap.Int("rows", rows), zap.Error(err))
+		return
+	}
+
+	if out == nil {
+		if err := start(); err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to stream events", zap.Int("rows", rows), zap.Error(err))
+			return
+		}
+	}
+	if err := out.Flush(); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to stream events", zap.Int("rows", rows), zap.Error(err))
+	}
+}
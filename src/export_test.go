@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNegotiateListFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		format string
+		ok     bool
+	}{
+		{name: "Default", format: "json", ok: true},
+		{name: "Any", accept: "*/*", format: "json", ok: true},
+		{name: "CSV", accept: "text/csv", format: "csv", ok: true},
+		{name: "Text wildcard", accept: "text/*", format: "csv", ok: true},
+		{name: "JSON Lines", accept: "application/x-ndjson", format: "ndjson", ok: true},
+		{name: "Highest quality wins", accept: "application/json;q=0.5, text/csv", format: "csv", ok: true},
+		{name: "Refused JSON", accept: "application/json;q=0, */*", format: "csv", ok: true},
+		{name: "Unsupported type falls back to JSON", accept: "text/html", format: "json", ok: true},
+		{name: "Query parameter overrides Accept", query: "format=ndjson", accept: "text/csv", format: "ndjson", ok: true},
+		{name: "Unknown query format", query: "format=xml", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/events?"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			format, ok := negotiateListFormat(req)
+			if ok != tt.ok || format.name != tt.format {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.format, tt.ok, format.name, ok)
+			}
+		})
+	}
+}
+
+func TestListEventsFormats(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	r, _ := setupSpecTest(t)
+
+	for _, body := range []string{
+		`{"title":"Planning","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`,
+		`{"title":"=HYPERLINK(\"http://example.com\")","description":"Room 1, \"east\"\nBring notes","start_time":"2030-01-02T10:00:00Z","end_time":"2030-01-02T11:00:00Z"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "test-admin-key-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create event: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	list := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/events"+query, nil)
+		req.Header.Set("X-API-Key", "test-admin-key-123")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{list("", "text/csv"), list("?format=csv", "")} {
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+				t.Errorf("Expected CSV content type, got %q", ct)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+				t.Errorf("Expected an attachment, got %q", w.Header().Get("Content-Disposition"))
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to parse CSV: %v", err)
+			}
+			if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(csvEventHeader, ",") {
+				t.Fatalf("Expected a header and 2 rows, got %v", records)
+			}
+			if records[1][1] != "Planning" || records[1][2] != "" {
+				t.Errorf("Unexpected first row: %v", records[1])
+			}
+			if records[2][1] != `'=HYPERLINK("http://example.com")` {
+				t.Errorf("Expected the formula to be neutralised, got %q", records[2][1])
+			}
+			if records[2][2] != "Room 1, \"east\"\nBring notes" {
+				t.Errorf("Expected the description to round-trip, got %q", records[2][2])
+			}
+			if records[2][3] != "2030-01-02T10:00:00Z" {
+				t.Errorf("Expected RFC 3339 start_time, got %q", records[2][3])
+			}
+		}
+	})
+
+	t.Run("JSON Lines", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{list("", "application/x-ndjson"), list("?format=ndjson", "text/csv")} {
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson; charset=utf-8" {
+				t.Errorf("Expected JSON Lines content type, got %q", ct)
+			}
+
+			var titles []string
+			scanner := bufio.NewScanner(w.Body)
+			for scanner.Scan() {
+				var event Event
+				if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+					t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+				}
+				titles = append(titles, event.Title)
+			}
+			if len(titles) != 2 || titles[0] != "Planning" {
+				t.Errorf("Expected 2 events in start time order, got %v", titles)
+			}
+		}
+	})
+
+	t.Run("JSON by default", func(t *testing.T) {
+		for _, accept := range []string{"", "application/json", "text/html"} {
+			w := list("", accept)
+			var response ListEventsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Count != 2 {
+				t.Errorf("Accept %q: expected a JSON listing, got %d %s", accept, w.Code, w.Body.String())
+			}
+			if w.Header().Get("Vary") != "Accept" {
+				t.Errorf("Expected Vary: Accept, got %q", w.Header().Get("Vary"))
+			}
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if w := list("?format=xml", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+
+	// Streamed responses are validated against the spec like any other
+	if logs.Len() != 0 {
+		t.Errorf("Expected no response violations, got %v", logs.All())
+	}
+}
+
+func TestListEventsStreamsThroughMiddleware(t *testing.T) {
+	repo := NewMockEventRepository()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2*streamFlushInterval+1; i++ {
+		event := &Event{
+			Title:     fmt.Sprintf("Event %d", i),
+			StartTime: start.Add(time.Duration(i) * time.Hour),
+			EndTime:   start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+		}
+		if err := repo.Create(t.Context(), event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	handler := LoggingMiddleware(MetricsMiddleware(http.HandlerFunc(NewEventHandler(repo).ListEvents)))
+
+	req := httptest.NewRequest("GET", "/api/events?format=ndjson", nil)
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(w, req)
+
+	if !w.Flushed {
+		t.Error("Expected the stream to be flushed through the middleware response writers")
+	}
+	// Once when the headers are sent and once after each flush
+	if w.deadlines != 3 {
+		t.Errorf("Expected the write deadline to be extended 3 times, got %d", w.deadlines)
+	}
+	if lines := strings.Count(w.Body.String(), "\n"); lines != 2*streamFlushInterval+1 {
+		t.Errorf("Expected %d lines, got %d", 2*streamFlushInterval+1, lines)
+	}
+}
+
+// deadlineRecorder records write deadline extensions made through
+// http.ResponseController
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines int
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(time.Time) error {
+	d.deadlines++
+	return nil
+}
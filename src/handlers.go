@@ -2,39 +2,49 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 // EventHandler handles HTTP requests for events
 type EventHandler struct {
 	repo      EventRepositoryInterface
-	validator *validator.Validate
+	validator *RequestValidator
 }
 
 // NewEventHandler creates a new event handler
 func NewEventHandler(repo EventRepositoryInterface) *EventHandler {
 	return &EventHandler{
 		repo:      repo,
-		validator: validator.New(),
+		validator: NewRequestValidator(),
 	}
 }
 
-// ListEvents handles GET /api/events
+// ListEvents handles GET /api/events. Events are returned as JSON unless the
+// format query parameter or the Accept header asks for CSV or JSON Lines,
+// which are streamed from the database without holding the listing in memory.
 func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	defer func() {
 		RecordDBOperation("list", "events", time.Since(start))
 	}()
 
-	events, err := h.repo.List()
+	format, ok := negotiateListFormat(r)
+	if !ok {
+		errorResponse(w, r, CodeInvalidQueryParam, "format must be one of json, csv or ndjson", nil)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	if format.mediaType != "application/json" {
+		h.streamEvents(w, r, format)
+		return
+	}
+
+	events, err := h.repo.List(r.Context())
 	if err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve events", err)
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve events", err)
 		return
 	}
 
@@ -56,31 +66,20 @@ func (h *EventHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	event, err := h.repo.Get(id)
+	event, err := h.repo.Get(r.Context(), id)
 	if err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve event", err)
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve event", err)
 		return
 	}
 
 	if event == nil {
-		h.errorResponse(w, http.StatusNotFound, "Event not found", nil)
+		errorResponse(w, r, CodeEventNotFound, "Event not found", nil)
 		return
 	}
 
 	h.jsonResponse(w, http.StatusOK, event)
 }
 
-// sanitizeString removes potentially dangerous characters from input strings
-func sanitizeString(s string) string {
-	// Replace HTML special characters with their escaped versions
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "\"", "&quot;")
-	s = strings.ReplaceAll(s, "'", "&#39;")
-	return s
-}
-
 // CreateEvent handles POST /api/events
 func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -89,40 +88,20 @@ func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	var req CreateEventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid request body", err)
-		return
-	}
-
-	// Validate request
-	if err := h.validator.Struct(req); err != nil {
-		h.validationErrorResponse(w, err)
+	if !h.validator.Decode(w, r, &req) {
 		return
 	}
 
-	// Sanitize input strings
-	req.Title = sanitizeString(req.Title)
-	if req.Description != nil {
-		sanitizedDesc := sanitizeString(*req.Description)
-		req.Description = &sanitizedDesc
-	}
-
 	// Parse times
 	startTime, err := time.Parse(time.RFC3339, req.StartTime)
 	if err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid start_time format", err)
+		errorResponse(w, r, CodeTimeFormatInvalid, "Invalid start_time format", err)
 		return
 	}
 
 	endTime, err := time.Parse(time.RFC3339, req.EndTime)
 	if err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid end_time format", err)
-		return
-	}
-
-	// Validate time range
-	if endTime.Before(startTime) || endTime.Equal(startTime) {
-		h.errorResponse(w, http.StatusBadRequest, "end_time must be after start_time", nil)
+		errorResponse(w, r, CodeTimeFormatInvalid, "Invalid end_time format", err)
 		return
 	}
 
@@ -134,15 +113,21 @@ func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		EndTime:     endTime,
 	}
 
-	if err := h.repo.Create(event); err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to create event", err)
+	err = h.repo.WithTransaction(r.Context(), func(tx EventRepositoryInterface) error {
+		if err := tx.Create(r.Context(), event); err != nil {
+			return err
+		}
+		return tx.RecordRevision(r.Context(), newRevision(r.Context(), RevisionActionCreate, event))
+	})
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to create event", err)
 		return
 	}
 
 	// Record metrics
 	eventsCreatedTotal.Inc()
-	activeEvents.Inc()
 
+	AddAuditTarget(r.Context(), "id", event.ID)
 	h.jsonResponse(w, http.StatusCreated, event)
 }
 
@@ -157,51 +142,31 @@ func (h *EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	var req UpdateEventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid request body", err)
-		return
-	}
-
-	// Validate request
-	if err := h.validator.Struct(req); err != nil {
-		h.validationErrorResponse(w, err)
+	if !h.validator.Decode(w, r, &req) {
 		return
 	}
 
-	// Sanitize input strings
-	req.Title = sanitizeString(req.Title)
-	if req.Description != nil {
-		sanitizedDesc := sanitizeString(*req.Description)
-		req.Description = &sanitizedDesc
-	}
-
 	// Check if event exists
-	existing, err := h.repo.Get(id)
+	existing, err := h.repo.Get(r.Context(), id)
 	if err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve event", err)
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve event", err)
 		return
 	}
 	if existing == nil {
-		h.errorResponse(w, http.StatusNotFound, "Event not found", nil)
+		errorResponse(w, r, CodeEventNotFound, "Event not found", nil)
 		return
 	}
 
 	// Parse times
 	startTime, err := time.Parse(time.RFC3339, req.StartTime)
 	if err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid start_time format", err)
+		errorResponse(w, r, CodeTimeFormatInvalid, "Invalid start_time format", err)
 		return
 	}
 
 	endTime, err := time.Parse(time.RFC3339, req.EndTime)
 	if err != nil {
-		h.errorResponse(w, http.StatusBadRequest, "Invalid end_time format", err)
-		return
-	}
-
-	// Validate time range
-	if endTime.Before(startTime) || endTime.Equal(startTime) {
-		h.errorResponse(w, http.StatusBadRequest, "end_time must be after start_time", nil)
+		errorResponse(w, r, CodeTimeFormatInvalid, "Invalid end_time format", err)
 		return
 	}
 
@@ -215,19 +180,26 @@ func (h *EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:   existing.CreatedAt,
 	}
 
-	if updateErr := h.repo.Update(id, event); updateErr != nil {
+	var updated *Event
+	updateErr := h.repo.WithTransaction(r.Context(), func(tx EventRepositoryInterface) error {
+		if err := tx.Update(r.Context(), id, event); err != nil {
+			return err
+		}
+
+		// Get updated event
+		var err error
+		updated, err = tx.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return tx.RecordRevision(r.Context(), newRevision(r.Context(), RevisionActionUpdate, updated))
+	})
+	if updateErr != nil {
 		if updateErr == sql.ErrNoRows {
-			h.errorResponse(w, http.StatusNotFound, "Event not found", nil)
+			errorResponse(w, r, CodeEventNotFound, "Event not found", nil)
 			return
 		}
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to update event", updateErr)
-		return
-	}
-
-	// Get updated event
-	updated, err := h.repo.Get(id)
-	if err != nil {
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve updated event", err)
+		errorResponse(w, r, CodeInternalError, "Failed to update event", updateErr)
 		return
 	}
 
@@ -244,45 +216,92 @@ func (h *EventHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := h.repo.Delete(id); err != nil {
+	err := h.repo.WithTransaction(r.Context(), func(tx EventRepositoryInterface) error {
+		existing, err := tx.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return sql.ErrNoRows
+		}
+		if err := tx.Delete(r.Context(), id); err != nil {
+			return err
+		}
+
+		deleted := *existing
+		now := time.Now().UTC()
+		deleted.DeletedAt = &now
+		return tx.RecordRevision(r.Context(), newRevision(r.Context(), RevisionActionDelete, &deleted))
+	})
+	if err != nil {
 		if err == sql.ErrNoRows {
-			h.errorResponse(w, http.StatusNotFound, "Event not found", nil)
+			errorResponse(w, r, CodeEventNotFound, "Event not found", nil)
 			return
 		}
-		h.errorResponse(w, http.StatusInternalServerError, "Failed to delete event", err)
+		errorResponse(w, r, CodeInternalError, "Failed to delete event", err)
 		return
 	}
 
 	// Record metrics
 	eventsDeletedTotal.Inc()
-	activeEvents.Dec()
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HealthCheck handles GET /health
-func (h *EventHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	status := "healthy"
-	dbStatus := "connected"
+// ListTrash handles GET /api/trash
+func (h *EventHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("list_trash", "events", time.Since(start))
+	}()
 
-	// Check database connection
-	if err := h.repo.Ping(); err != nil {
-		status = "unhealthy"
-		dbStatus = "disconnected"
+	events, err := h.repo.ListTrash(r.Context())
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve deleted events", err)
+		return
 	}
 
-	response := HealthResponse{
-		Status:    status,
-		Timestamp: time.Now().UTC(),
-		Database:  dbStatus,
+	response := ListEventsResponse{
+		Events: events,
+		Count:  len(events),
 	}
 
-	statusCode := http.StatusOK
-	if status == "unhealthy" {
-		statusCode = http.StatusServiceUnavailable
+	h.jsonResponse(w, http.StatusOK, response)
+}
+
+// RestoreEvent handles POST /api/events/{id}:restore
+func (h *EventHandler) RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("restore", "events", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var restored *Event
+	err := h.repo.WithTransaction(r.Context(), func(tx EventRepositoryInterface) error {
+		if err := tx.Restore(r.Context(), id); err != nil {
+			return err
+		}
+
+		var err error
+		restored, err = tx.Get(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		return tx.RecordRevision(r.Context(), newRevision(r.Context(), RevisionActionRestore, restored))
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeEventNotInTrash, "Deleted event not found", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to restore event", err)
+		return
 	}
 
-	h.jsonResponse(w, statusCode, response)
+	h.jsonResponse(w, http.StatusOK, restored)
 }
 
 // VersionCheck handles GET /version
@@ -299,34 +318,5 @@ func (h *EventHandler) VersionCheck(w http.ResponseWriter, r *http.Request) {
 // Helper methods
 
 func (h *EventHandler) jsonResponse(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func (h *EventHandler) errorResponse(w http.ResponseWriter, status int, message string, _ error) {
-	response := ErrorResponse{
-		Error:   http.StatusText(status),
-		Message: message,
-	}
-
-	h.jsonResponse(w, status, response)
-}
-
-func (h *EventHandler) validationErrorResponse(w http.ResponseWriter, err error) {
-	response := ErrorResponse{
-		Error:   "Validation Failed",
-		Message: "Request validation failed",
-		Details: make(map[string]string),
-	}
-
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		for _, e := range validationErrors {
-			response.Details[e.Field()] = e.Tag()
-		}
-	}
-
-	h.jsonResponse(w, http.StatusBadRequest, response)
+	jsonResponse(w, status, data)
 }
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Health check statuses
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// Health check names
+const (
+	HealthCheckDatabase   = "database"
+	HealthCheckPool       = "database_pool"
+	HealthCheckMigrations = "migrations"
+)
+
+// healthCheckTimeout bounds how long a single check may take
+const healthCheckTimeout = 2 * time.Second
+
+// pingCacheTTL is how long a database ping result is reused. The probes are
+// unauthenticated and not rate limited, so callers must not be able to turn
+// them into database load.
+const pingCacheTTL = time.Second
+
+// poolSaturationGrace is how long every pooled connection may stay in use
+// before readiness fails. Short bursts are reported in the details only.
+const poolSaturationGrace = 30 * time.Second
+
+// HealthCheckFunc checks a single dependency. The returned details are
+// included in verbose health output.
+type HealthCheckFunc func(ctx context.Context) (map[string]interface{}, error)
+
+// HealthCheckResult is the outcome of running a single check. Error text
+// is logged rather than returned, since /health is public.
+type HealthCheckResult struct {
+	Name        string                 `json:"name"`
+	Status      string                 `json:"status"`
+	DurationMS  float64                `json:"duration_ms"`
+	LastFailure *time.Time             `json:"last_failure,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// namedHealthCheck is a registered check
+type namedHealthCheck struct {
+	name  string
+	check HealthCheckFunc
+}
+
+// HealthChecker runs the registered dependency checks and remembers when
+// each last failed
+type HealthChecker struct {
+	checks []namedHealthCheck
+
+	mu       sync.Mutex
+	failures map[string]time.Time
+}
+
+// NewHealthChecker creates a health checker without any checks
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{failures: make(map[string]time.Time)}
+}
+
+// Register adds a check. Checks run in registration order.
+func (h *HealthChecker) Register(name string, check HealthCheckFunc) {
+	h.checks = append(h.checks, namedHealthCheck{name: name, check: check})
+}
+
+// Run runs every check and reports whether all of them passed
+func (h *HealthChecker) Run(ctx context.Context) (bool, []HealthCheckResult) {
+	healthy := true
+	results := make([]HealthCheckResult, 0, len(h.checks))
+
+	for _, c := range h.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		start := time.Now()
+		details, err := c.check(checkCtx)
+		duration := time.Since(start)
+		cancel()
+
+		result := HealthCheckResult{
+			Name:       c.name,
+			Status:     HealthStatusHealthy,
+			DurationMS: float64(duration.Microseconds()) / 1000,
+			Details:    details,
+		}
+
+		if err != nil {
+			LoggerFromContext(ctx).Warn("Health check failed", zap.String("check", c.name), zap.Error(err))
+		}
+
+		h.mu.Lock()
+		if err != nil {
+			healthy = false
+			result.Status = HealthStatusUnhealthy
+			h.failures[c.name] = start.UTC()
+		}
+		if at, ok := h.failures[c.name]; ok {
+			result.LastFailure = &at
+		}
+		h.mu.Unlock()
+
+		results = append(results, result)
+	}
+
+	return healthy, results
+}
+
+// DatabasePingCheck checks that the database answers a ping and reports the
+// round-trip latency. A result is reused for ttl, and concurrent checks wait
+// for the ping in progress rather than sending their own.
+func DatabasePingCheck(ping func(ctx context.Context) error, ttl time.Duration) HealthCheckFunc {
+	var (
+		mu       sync.Mutex
+		details  map[string]interface{}
+		err      error
+		pingedAt time.Time
+	)
+
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if details != nil && time.Since(pingedAt) < ttl {
+			return details, err
+		}
+
+		start := time.Now()
+		pingErr := ping(ctx)
+		result := map[string]interface{}{
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if pingErr != nil {
+			pingErr = fmt.Errorf("database ping failed: %w", pingErr)
+		}
+
+		// A caller that gave up must not fail the check for everyone else
+		if ctx.Err() == nil {
+			details, err, pingedAt = result, pingErr, start
+		}
+		return result, pingErr
+	}
+}
+
+// DatabasePoolCheck reports connection pool usage and fails once every
+// connection the pool may open has been in use for at least grace
+func DatabasePoolCheck(stats func() sql.DBStats, grace time.Duration) HealthCheckFunc {
+	var (
+		mu             sync.Mutex
+		saturatedSince time.Time
+	)
+
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		s := stats()
+		details := map[string]interface{}{
+			"open":             s.OpenConnections,
+			"in_use":           s.InUse,
+			"idle":             s.Idle,
+			"max_open":         s.MaxOpenConnections,
+			"wait_count":       s.WaitCount,
+			"wait_duration_ms": s.WaitDuration.Milliseconds(),
+		}
+
+		if s.MaxOpenConnections > 0 {
+			details["saturation"] = float64(s.InUse) / float64(s.MaxOpenConnections)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if s.InUse < s.MaxOpenConnections {
+				saturatedSince = time.Time{}
+				return details, nil
+			}
+
+			now := time.Now()
+			if saturatedSince.IsZero() {
+				saturatedSince = now
+			}
+			details["saturated_since"] = saturatedSince.UTC()
+			if saturated := now.Sub(saturatedSince); saturated >= grace {
+				return details, fmt.Errorf("all %d database connections have been in use for %s", s.MaxOpenConnections, saturated.Round(time.Second))
+			}
+		}
+		return details, nil
+	}
+}
+
+// MigrationCheck fails while any migration is pending. Migrations only run
+// at startup, so once none are pending the status is not queried again.
+func MigrationCheck(status func() ([]Migration, error)) HealthCheckFunc {
+	var (
+		mu      sync.Mutex
+		current map[string]interface{}
+	)
+
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if current != nil {
+			return current, nil
+		}
+
+		migrations, err := status()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get migration status: %w", err)
+		}
+
+		applied, latest := 0, ""
+		var pending []string
+		for _, migration := range migrations {
+			if migration.Applied {
+				applied++
+				if migration.Version > latest {
+					latest = migration.Version
+				}
+			} else {
+				pending = append(pending, migration.Version)
+			}
+		}
+
+		details := map[string]interface{}{
+			"applied": applied,
+			"pending": len(pending),
+			"latest":  latest,
+		}
+		if len(pending) > 0 {
+			return details, fmt.Errorf("%d migrations are pending: %v", len(pending), pending)
+		}
+
+		current = details
+		return details, nil
+	}
+}
+
+// HealthHandler serves the liveness, readiness and health endpoints
+type HealthHandler struct {
+	checker *HealthChecker
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(checker *HealthChecker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Livez handles GET /livez. It only reports that the process is serving
+// requests and does not check dependencies.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, LivenessResponse{
+		Status:    HealthStatusHealthy,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// Readyz handles GET /readyz. It returns 503 while any dependency check
+// fails so that the load balancer stops routing traffic to this task.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	healthy, results := h.checker.Run(r.Context())
+
+	response := ReadinessResponse{
+		Status:    HealthStatusHealthy,
+		Timestamp: time.Now().UTC(),
+		Checks:    make(map[string]string, len(results)),
+	}
+	for _, result := range results {
+		response.Checks[result.Name] = result.Status
+	}
+
+	statusCode := http.StatusOK
+	if !healthy {
+		response.Status = HealthStatusUnhealthy
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	jsonResponse(w, statusCode, response)
+}
+
+// Health handles GET /health. With ?verbose=1 the response lists every
+// check with its duration, details and the time of its last failure.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	healthy, results := h.checker.Run(r.Context())
+
+	response := HealthResponse{
+		Status:    HealthStatusHealthy,
+		Timestamp: time.Now().UTC(),
+		Database:  "connected",
+	}
+	for _, result := range results {
+		if result.Name == HealthCheckDatabase && result.Status != HealthStatusHealthy {
+			response.Database = "disconnected"
+		}
+	}
+	if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); verbose {
+		response.Checks = results
+	}
+
+	statusCode := http.StatusOK
+	if !healthy {
+		response.Status = HealthStatusUnhealthy
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	jsonResponse(w, statusCode, response)
+}
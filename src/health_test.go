@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecks(t *testing.T) {
+	t.Run("Pool saturation", func(t *testing.T) {
+		stats := sql.DBStats{MaxOpenConnections: 4, OpenConnections: 4, InUse: 3}
+		check := DatabasePoolCheck(func() sql.DBStats { return stats }, 0)
+
+		details, err := check(context.Background())
+		if err != nil {
+			t.Fatalf("Expected pool with a free connection to pass, got %v", err)
+		}
+		if details["saturation"] != 0.75 {
+			t.Errorf("Expected saturation 0.75, got %v", details["saturation"])
+		}
+
+		stats.InUse = 4
+		if _, err := check(context.Background()); err == nil {
+			t.Error("Expected saturated pool to fail")
+		}
+	})
+
+	t.Run("Brief pool saturation", func(t *testing.T) {
+		stats := sql.DBStats{MaxOpenConnections: 4, OpenConnections: 4, InUse: 4}
+		check := DatabasePoolCheck(func() sql.DBStats { return stats }, time.Hour)
+
+		details, err := check(context.Background())
+		if err != nil {
+			t.Fatalf("Expected saturation within the grace period to pass, got %v", err)
+		}
+		if _, ok := details["saturated_since"]; !ok {
+			t.Errorf("Expected saturation to be reported in details, got %v", details)
+		}
+
+		stats.InUse = 2
+		if details, _ := check(context.Background()); details["saturated_since"] != nil {
+			t.Errorf("Expected saturation to clear once a connection is free, got %v", details)
+		}
+	})
+
+	t.Run("Ping results are cached", func(t *testing.T) {
+		pings := 0
+		check := DatabasePingCheck(func(ctx context.Context) error {
+			pings++
+			return nil
+		}, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			if _, err := check(context.Background()); err != nil {
+				t.Fatalf("Expected ping to pass, got %v", err)
+			}
+		}
+		if pings != 1 {
+			t.Errorf("Expected a single ping, got %d", pings)
+		}
+	})
+
+	t.Run("Abandoned pings are not cached", func(t *testing.T) {
+		pings := 0
+		check := DatabasePingCheck(func(ctx context.Context) error {
+			pings++
+			return ctx.Err()
+		}, time.Hour)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := check(ctx); err == nil {
+			t.Fatal("Expected the cancelled ping to fail")
+		}
+		if _, err := check(context.Background()); err != nil || pings != 2 {
+			t.Errorf("Expected a fresh ping after a cancelled one, got %v after %d pings", err, pings)
+		}
+	})
+
+	t.Run("Pending migrations", func(t *testing.T) {
+		calls := 0
+		migrations := []Migration{{Version: "001", Applied: true}, {Version: "002"}}
+		check := MigrationCheck(func() ([]Migration, error) {
+			calls++
+			return migrations, nil
+		})
+
+		if _, err := check(context.Background()); err == nil {
+			t.Fatal("Expected pending migration to fail the check")
+		}
+
+		migrations[1].Applied = true
+		details, err := check(context.Background())
+		if err != nil {
+			t.Fatalf("Expected applied migrations to pass, got %v", err)
+		}
+		if details["latest"] != "002" || details["pending"] != 0 {
+			t.Errorf("Unexpected details: %v", details)
+		}
+
+		if _, err := check(context.Background()); err != nil || calls != 2 {
+			t.Errorf("Expected migration status to be cached once up to date, got %d calls", calls)
+		}
+	})
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	var pingErr error
+	checker := NewHealthChecker()
+	checker.Register(HealthCheckDatabase, DatabasePingCheck(func(ctx context.Context) error { return pingErr }, 0))
+	checker.Register(HealthCheckMigrations, func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"pending": 0}, nil
+	})
+	handler := NewHealthHandler(checker)
+
+	serve := func(h http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		if w := serve(handler.Livez, "/livez"); w.Code != http.StatusOK {
+			t.Errorf("Expected liveness status 200, got %d", w.Code)
+		}
+
+		w := serve(handler.Readyz, "/readyz")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected readiness status 200, got %d", w.Code)
+		}
+		var readiness ReadinessResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if readiness.Checks[HealthCheckDatabase] != HealthStatusHealthy || readiness.Checks[HealthCheckMigrations] != HealthStatusHealthy {
+			t.Errorf("Unexpected checks: %v", readiness.Checks)
+		}
+
+		var health HealthResponse
+		w = serve(handler.Health, "/health")
+		if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if health.Status != HealthStatusHealthy || health.Database != "connected" || health.Checks != nil {
+			t.Errorf("Unexpected health response: %+v", health)
+		}
+	})
+
+	t.Run("Database down", func(t *testing.T) {
+		pingErr = errors.New("connection refused")
+		defer func() { pingErr = nil }()
+
+		if w := serve(handler.Livez, "/livez"); w.Code != http.StatusOK {
+			t.Errorf("Expected liveness to ignore dependencies, got %d", w.Code)
+		}
+		if w := serve(handler.Readyz, "/readyz"); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected readiness status 503, got %d", w.Code)
+		}
+		if w := serve(handler.Health, "/health"); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected health status 503, got %d", w.Code)
+		}
+	})
+
+	t.Run("Verbose output keeps the last failure", func(t *testing.T) {
+		w := serve(handler.Health, "/health?verbose=1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 after recovery, got %d", w.Code)
+		}
+
+		var health HealthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(health.Checks) != 2 {
+			t.Fatalf("Expected 2 checks, got %d", len(health.Checks))
+		}
+
+		database := health.Checks[0]
+		if database.Name != HealthCheckDatabase || database.Status != HealthStatusHealthy {
+			t.Errorf("Unexpected database check: %+v", database)
+		}
+		if database.LastFailure == nil {
+			t.Error("Expected the last database failure to be reported")
+		}
+		if strings.Contains(w.Body.String(), "connection refused") {
+			t.Errorf("Expected dependency errors to be kept out of the public response, got %s", w.Body.String())
+		}
+		if _, ok := database.Details["latency_ms"]; !ok {
+			t.Error("Expected ping latency in details")
+		}
+		if health.Checks[1].LastFailure != nil {
+			t.Error("Expected no failure for the migrations check")
+		}
+	})
+}
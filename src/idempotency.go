@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make retries safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the idempotency_keys.idempotency_key column size
+const maxIdempotencyKeyLength = 255
+
+// idempotencyReservationLease is how long an unfinished reservation holds its
+// key. A reservation older than this was left behind by a request that
+// crashed or was killed, so a retry may take the key over. It is longer than
+// the server's write timeout, after which the original client can no longer
+// receive a response anyway.
+const idempotencyReservationLease = 2 * serverWriteTimeout
+
+// idempotencyReserveAttempts bounds how often Reserve retries when the
+// conflicting record disappears before it can be read
+const idempotencyReserveAttempts = 3
+
+// errIdempotencyKeyContended is returned by Reserve when the key kept
+// changing hands across every attempt
+var errIdempotencyKeyContended = errors.New("idempotency key is contended")
+
+// IdempotencyRecord represents a stored request/response pair for an idempotency key
+type IdempotencyRecord struct {
+	UserID       string
+	Key          string
+	RequestHash  string
+	StatusCode   int // 0 while the original request is still being processed
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time // when the key was reserved
+	ExpiresAt    time.Time
+}
+
+// IdempotencyStoreInterface defines the interface for idempotency key storage
+type IdempotencyStoreInterface interface {
+	Reserve(ctx context.Context, record *IdempotencyRecord, lease time.Duration) (*IdempotencyRecord, error)
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	Release(ctx context.Context, record *IdempotencyRecord) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// IdempotencyRepository handles database operations for idempotency keys
+type IdempotencyRepository struct {
+	db *DB
+}
+
+// NewIdempotencyRepository creates a new idempotency repository
+func NewIdempotencyRepository(db *DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims an idempotency key for a new request. If the key is already
+// held by an unexpired record, that record is returned instead and nothing is
+// reserved; a nil record means the caller now owns the key. Reservations that
+// have not completed within lease are treated as abandoned and taken over.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *IdempotencyRecord, lease time.Duration) (*IdempotencyRecord, error) {
+	ctx, span := startSpan(ctx, "IdempotencyRepository.Reserve")
+	defer span.End()
+
+	for attempt := 0; attempt < idempotencyReserveAttempts; attempt++ {
+		reserved, existing, err := r.reserve(ctx, record, lease)
+		if err != nil || reserved || existing != nil {
+			return existing, err
+		}
+		// The conflicting row was removed between the insert and the lookup
+	}
+
+	return nil, errIdempotencyKeyContended
+}
+
+// reserve makes a single attempt at claiming the key. It reports whether the
+// key was reserved, or else the record holding it, which is nil if that
+// record was removed before it could be read.
+func (r *IdempotencyRepository) reserve(ctx context.Context, record *IdempotencyRecord, lease time.Duration) (bool, *IdempotencyRecord, error) {
+	// Postgres stores microseconds; CreatedAt identifies the reservation later
+	record.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	// Expired keys and abandoned reservations are taken over in place so a
+	// stale row never blocks a new request
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, status_code, created_at, expires_at)
+		VALUES ($1, $2, $3, 0, $4, $5)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = 0,
+			content_type = '',
+			response_body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+			OR (idempotency_keys.status_code = 0 AND idempotency_keys.created_at <= $6)
+	`
+
+	result, err := r.db.ExecContext(ctx,
+		query,
+		record.UserID,
+		record.Key,
+		record.RequestHash,
+		record.CreatedAt,
+		record.ExpiresAt,
+		record.CreatedAt.Add(-lease),
+	)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected > 0 {
+		return true, nil, nil
+	}
+
+	existing, err := r.get(ctx, record.UserID, record.Key)
+	return false, existing, err
+}
+
+// get retrieves the record stored for a user's idempotency key
+func (r *IdempotencyRepository) get(ctx context.Context, userID, key string) (*IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, idempotency_key, request_hash, status_code, content_type, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	var record IdempotencyRecord
+	err := r.db.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return &record, nil
+}
+
+// Complete stores the response for a reserved idempotency key. Nothing is
+// stored if the reservation has since been taken over by a retry.
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	ctx, span := startSpan(ctx, "IdempotencyRepository.Complete")
+	defer span.End()
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE user_id = $1 AND idempotency_key = $2 AND created_at = $6 AND status_code = 0
+	`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		record.UserID,
+		record.Key,
+		record.StatusCode,
+		record.ContentType,
+		record.ResponseBody,
+		record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Release removes a reserved idempotency key so the request can be retried.
+// A reservation that has since been taken over by a retry is left alone.
+func (r *IdempotencyRepository) Release(ctx context.Context, record *IdempotencyRecord) error {
+	ctx, span := startSpan(ctx, "IdempotencyRepository.Release")
+	defer span.End()
+
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND created_at = $3 AND status_code = 0
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, record.UserID, record.Key, record.CreatedAt); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all idempotency keys that expired before now
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "IdempotencyRepository.DeleteExpired")
+	defer span.End()
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+
+	result, err := r.db.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// IdempotencyMiddleware replays stored responses for retried requests
+type IdempotencyMiddleware struct {
+	store IdempotencyStoreInterface
+	ttl   time.Duration
+}
+
+// NewIdempotencyMiddleware creates a new idempotency middleware
+func NewIdempotencyMiddleware(store IdempotencyStoreInterface, config *Config) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		store: store,
+		ttl:   config.IdempotencyKeyTTL,
+	}
+}
+
+// WithIdempotency middleware that honours the Idempotency-Key header.
+// It must run after authentication since keys are scoped per user.
+func (m *IdempotencyMiddleware) WithIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			errorResponse(w, r, CodeIdempotencyKeyInvalid, fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength), nil)
+			return
+		}
+
+		user, ok := GetUser(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			bodyErrorResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := &IdempotencyRecord{
+			UserID:      user.ID,
+			Key:         key,
+			RequestHash: hashIdempotentRequest(r, body),
+			ExpiresAt:   time.Now().UTC().Add(m.ttl),
+		}
+
+		existing, err := m.store.Reserve(r.Context(), record, idempotencyReservationLease)
+		if errors.Is(err, errIdempotencyKeyContended) {
+			errorResponse(w, r, CodeIdempotencyKeyInProgress, fmt.Sprintf("A request with this %s is still being processed", IdempotencyKeyHeader), nil)
+			return
+		}
+		if err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to reserve idempotency key", zap.Error(err))
+			errorResponse(w, r, CodeInternalError, "Failed to process idempotency key", nil)
+			return
+		}
+
+		if existing != nil {
+			m.replay(w, r, existing, record.RequestHash)
+			return
+		}
+
+		rec := &recordingResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+		next.ServeHTTP(rec, r)
+
+		// Server errors are not stored so that the client can retry with the same key
+		if rec.statusCode >= http.StatusInternalServerError {
+			if err := m.store.Release(r.Context(), record); err != nil {
+				LoggerFromContext(r.Context()).Error("Failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+
+		record.StatusCode = rec.statusCode
+		record.ContentType = rec.Header().Get("Content-Type")
+		record.ResponseBody = rec.body.Bytes()
+		if err := m.store.Complete(r.Context(), record); err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to store idempotent response", zap.Error(err))
+		}
+	})
+}
+
+// replay writes the stored response for an existing idempotency key
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, existing *IdempotencyRecord, requestHash string) {
+	if existing.RequestHash != requestHash {
+		errorResponse(w, r, CodeIdempotencyKeyReused, fmt.Sprintf("%s has already been used with a different request", IdempotencyKeyHeader), nil)
+		return
+	}
+
+	if existing.StatusCode == 0 {
+		errorResponse(w, r, CodeIdempotencyKeyInProgress, fmt.Sprintf("A request with this %s is still being processed", IdempotencyKeyHeader), nil)
+		return
+	}
+
+	log := LoggerFromContext(r.Context())
+	log.Debug("Replaying stored response for idempotency key")
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(existing.StatusCode)
+	if _, err := w.Write(existing.ResponseBody); err != nil {
+		log.Warn("Failed to write replayed response", zap.Error(err))
+	}
+}
+
+// hashIdempotentRequest fingerprints the parts of a request that must match on retry
+func hashIdempotentRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingResponseWriter captures the status code and body of a response
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// MockIdempotencyStore implements IdempotencyStoreInterface for testing
+type MockIdempotencyStore struct {
+	records    map[string]*IdempotencyRecord
+	reserveErr error
+}
+
+func NewMockIdempotencyStore() *MockIdempotencyStore {
+	return &MockIdempotencyStore{
+		records: make(map[string]*IdempotencyRecord),
+	}
+}
+
+func (m *MockIdempotencyStore) Reserve(ctx context.Context, record *IdempotencyRecord, lease time.Duration) (*IdempotencyRecord, error) {
+	if m.reserveErr != nil {
+		return nil, m.reserveErr
+	}
+	id := record.UserID + "/" + record.Key
+	record.CreatedAt = time.Now()
+	if existing, ok := m.records[id]; ok && existing.ExpiresAt.After(record.CreatedAt) {
+		if existing.StatusCode != 0 || existing.CreatedAt.After(record.CreatedAt.Add(-lease)) {
+			return existing, nil
+		}
+	}
+	stored := *record
+	m.records[id] = &stored
+	return nil, nil
+}
+
+func (m *MockIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	stored := *record
+	m.records[record.UserID+"/"+record.Key] = &stored
+	return nil
+}
+
+func (m *MockIdempotencyStore) Release(ctx context.Context, record *IdempotencyRecord) error {
+	delete(m.records, record.UserID+"/"+record.Key)
+	return nil
+}
+
+func (m *MockIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	for id, record := range m.records {
+		if !record.ExpiresAt.After(now) {
+			delete(m.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	store := NewMockIdempotencyStore()
+	middleware := NewIdempotencyMiddleware(store, &Config{IdempotencyKeyTTL: time.Hour})
+
+	calls := 0
+	status := http.StatusCreated
+	handler := middleware.WithIdempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		jsonResponse(w, status, map[string]int{"call": calls})
+	}))
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		req = req.WithContext(WithUser(req.Context(), &User{ID: "test-user", Username: "testuser"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("No key passes through", func(t *testing.T) {
+		send("", `{"title":"a"}`)
+		send("", `{"title":"a"}`)
+		if calls != 2 {
+			t.Errorf("Expected handler to be called twice, got %d", calls)
+		}
+	})
+
+	t.Run("Retry replays original response", func(t *testing.T) {
+		calls = 0
+		first := send("key-1", `{"title":"a"}`)
+		second := send("key-1", `{"title":"a"}`)
+
+		if calls != 1 {
+			t.Errorf("Expected handler to be called once, got %d", calls)
+		}
+		if second.Code != http.StatusCreated {
+			t.Errorf("Expected replayed status %d, got %d", http.StatusCreated, second.Code)
+		}
+		if second.Body.String() != first.Body.String() {
+			t.Errorf("Expected replayed body %q, got %q", first.Body.String(), second.Body.String())
+		}
+		if second.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("Expected Idempotent-Replayed header on replay")
+		}
+	})
+
+	t.Run("Reuse with different body", func(t *testing.T) {
+		w := send("key-1", `{"title":"b"}`)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("Request still in flight", func(t *testing.T) {
+		store.records["test-user/key-2"] = &IdempotencyRecord{
+			UserID:      "test-user",
+			Key:         "key-2",
+			RequestHash: hashIdempotentRequest(httptest.NewRequest(http.MethodPost, "/api/events", nil), []byte(`{}`)),
+			CreatedAt:   time.Now(),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		w := send("key-2", `{}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("Abandoned reservation is taken over", func(t *testing.T) {
+		calls = 0
+		store.records["test-user/key-2"].CreatedAt = time.Now().Add(-idempotencyReservationLease - time.Second)
+		w := send("key-2", `{}`)
+		if calls != 1 || w.Code != http.StatusCreated {
+			t.Errorf("Expected the retry to run the handler, got %d calls and status %d", calls, w.Code)
+		}
+	})
+
+	t.Run("Contended key", func(t *testing.T) {
+		calls = 0
+		store.reserveErr = errIdempotencyKeyContended
+		defer func() { store.reserveErr = nil }()
+
+		w := send("key-4", `{}`)
+		if calls != 0 || w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d without running the handler, got %d calls and status %d", http.StatusConflict, calls, w.Code)
+		}
+	})
+
+	t.Run("Server errors are not stored", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+		send("key-3", `{}`)
+		status = http.StatusCreated
+		w := send("key-3", `{}`)
+
+		if calls != 2 {
+			t.Errorf("Expected handler to be called twice, got %d", calls)
+		}
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+	})
+
+	t.Run("Expired keys are cleaned up", func(t *testing.T) {
+		deleted, _ := store.DeleteExpired(context.Background(), time.Now().Add(2*time.Hour))
+		if deleted == 0 {
+			t.Error("Expected expired keys to be deleted")
+		}
+		if len(store.records) != 0 {
+			t.Errorf("Expected no records left, got %d", len(store.records))
+		}
+	})
+}
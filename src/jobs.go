@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RunPeriodic runs fn every interval until the context is cancelled.
+// Errors are logged and do not stop the job.
+func RunPeriodic(ctx context.Context, interval time.Duration, name string, fn func() error) {
+	log := logger.With(zap.String("job", name))
+	log.Info("Starting background job", zap.Duration("interval", interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Stopped background job")
+			return
+		case <-ticker.C:
+			if err := fn(); err != nil {
+				log.Error("Background job failed", zap.Error(err))
+			}
+		}
+	}
+}
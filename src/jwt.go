@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"go.uber.org/zap"
+)
+
+const (
+	// jwtClockSkew is the leeway allowed when checking exp, nbf and iat
+	jwtClockSkew = time.Minute
+
+	// jwksMinRefreshInterval limits refreshes triggered by unknown key IDs
+	jwksMinRefreshInterval = time.Minute
+)
+
+// jwtSignatureAlgorithms lists the asymmetric algorithms accepted for bearer tokens
+var jwtSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// JWTVerifier validates bearer tokens against a JSON Web Key Set loaded
+// from a file or URL
+type JWTVerifier struct {
+	load          func() ([]byte, error)
+	issuer        string
+	audience      string
+	usernameClaim string
+	refreshRate   time.Duration
+
+	mu       sync.Mutex
+	keys     *jose.JSONWebKeySet
+	loadedAt time.Time
+}
+
+// NewJWTVerifier creates a verifier from configuration. It returns nil when
+// bearer authentication is not configured.
+func NewJWTVerifier(config *Config) *JWTVerifier {
+	if !config.JWTEnabled() {
+		return nil
+	}
+
+	verifier := &JWTVerifier{
+		issuer:        config.JWTIssuer,
+		audience:      config.JWTAudience,
+		usernameClaim: config.JWTUsernameClaim,
+		refreshRate:   config.JWTJWKSRefreshRate,
+	}
+
+	if config.JWTJWKSFile != "" {
+		path := config.JWTJWKSFile
+		verifier.load = func() ([]byte, error) {
+			return os.ReadFile(path)
+		}
+	} else {
+		url := config.JWTJWKSURL
+		client := &http.Client{Timeout: 10 * time.Second}
+		verifier.load = func() ([]byte, error) {
+			resp, err := client.Get(url)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+			return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		}
+	}
+
+	return verifier
+}
+
+// JWTIdentity identifies the holder of a verified bearer token. Users are
+// bound to the issuer and subject; the username only names new users.
+// Scopes is nil when the token carries neither a scope nor an scp claim.
+type JWTIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Scopes   []string
+}
+
+// Verify validates a compact serialized JWT and returns the identity it was
+// issued for, with the username mapped from the configured claim
+func (v *JWTVerifier) Verify(token string) (*JWTIdentity, error) {
+	parsed, err := jwt.ParseSigned(token, jwtSignatureAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+
+	keys, err := v.keySet(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing keys may have been rotated since the key set was loaded
+	for _, header := range parsed.Headers {
+		if header.KeyID != "" && len(keys.Key(header.KeyID)) == 0 {
+			if keys, err = v.keySet(true); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var (
+		claims jwt.Claims
+		custom map[string]interface{}
+	)
+	if err := parsed.Claims(keys, &claims, &custom); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	// go-jose only checks the expiry of tokens that have one
+	if claims.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	expected := jwt.Expected{
+		Issuer:      v.issuer,
+		AnyAudience: jwt.Audience{v.audience},
+		Time:        time.Now(),
+	}
+	if err := claims.ValidateWithLeeway(expected, jwtClockSkew); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	username, _ := custom[v.usernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("token is missing the %q claim", v.usernameClaim)
+	}
+
+	return &JWTIdentity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Username: username,
+		Scopes:   tokenScopes(custom),
+	}, nil
+}
+
+// tokenScopes collects the scopes granted by the space-separated "scope"
+// claim and the "scp" claim, which may be a string or an array. It returns
+// nil when the token carries neither claim.
+func tokenScopes(claims map[string]interface{}) []string {
+	var scopes []string
+	found := false
+	for _, name := range []string{"scope", "scp"} {
+		switch value := claims[name].(type) {
+		case string:
+			found = true
+			scopes = append(scopes, strings.Fields(value)...)
+		case []interface{}:
+			found = true
+			for _, item := range value {
+				if scope, ok := item.(string); ok {
+					scopes = append(scopes, scope)
+				}
+			}
+		}
+	}
+
+	if found && scopes == nil {
+		return []string{}
+	}
+	return scopes
+}
+
+// keySet returns the cached key set, reloading it when it is older than the
+// refresh rate or when force is set and the minimum interval has passed.
+// A stale key set is kept if reloading fails.
+func (v *JWTVerifier) keySet(force bool) (*jose.JSONWebKeySet, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.loadedAt)
+	if v.keys != nil && age < v.refreshRate && (!force || age < jwksMinRefreshInterval) {
+		return v.keys, nil
+	}
+
+	data, err := v.load()
+	if err == nil {
+		var keys jose.JSONWebKeySet
+		if err = json.Unmarshal(data, &keys); err == nil {
+			v.keys = &keys
+			v.loadedAt = time.Now()
+			return v.keys, nil
+		}
+	}
+
+	if v.keys == nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	logger.Warn("Failed to refresh JWKS, using cached keys", zap.Error(err))
+	v.loadedAt = time.Now()
+	return v.keys, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+var (
+	// errJWTUserNotFound is returned when a valid token maps to an unknown
+	// user and auto-provisioning is disabled
+	errJWTUserNotFound = errors.New("no user matches the token")
+	// errJWTUsernameTaken is returned when a user would be provisioned under
+	// the username of an existing account
+	errJWTUsernameTaken = errors.New("username belongs to an existing account")
+)
+
+// userForToken resolves the user bound to a verified identity, creating it
+// when auto-provisioning is enabled. Tokens are never matched to accounts by
+// username, so API key users and the bootstrap admin cannot be signed in to
+// with a token.
+func (a *AuthMiddleware) userForToken(ctx context.Context, identity *JWTIdentity) (*User, error) {
+	user, err := a.userRepo.GetByIdentity(ctx, identity.Issuer, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	if !a.config.JWTAutoProvision {
+		return nil, errJWTUserNotFound
+	}
+
+	existing, err := a.userRepo.GetByUsername(ctx, identity.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errJWTUsernameTaken
+	}
+
+	user = &User{
+		Username: identity.Username,
+		Role:     a.config.JWTDefaultRole,
+	}
+	if err := a.userRepo.CreateWithIdentity(ctx, user, identity.Issuer, identity.Subject); err != nil {
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+
+	LoggerFromContext(ctx).Info("Provisioned user from JWT",
+		zap.String("username", user.Username),
+		zap.String("subject", identity.Subject),
+	)
+	return user, nil
+}
+
+// requireJWT authenticates a request carrying a bearer token
+func (a *AuthMiddleware) requireJWT(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	log := LoggerFromContext(r.Context())
+
+	identity, err := a.jwtVerifier.Verify(token)
+	if err != nil {
+		log.Info("Invalid bearer token", zap.Error(err))
+		a.authFailed(w, r, authFailureInvalidToken, CodeBearerTokenInvalid, "Invalid bearer token")
+		return
+	}
+
+	user, err := a.userForToken(r.Context(), identity)
+	if err == errJWTUserNotFound || err == errJWTUsernameTaken {
+		log.Info("Bearer token for unknown user",
+			zap.String("subject", identity.Subject),
+			zap.String("username", identity.Username),
+			zap.Error(err),
+		)
+		a.authFailed(w, r, authFailureUnknownUser, CodeUserUnknown, "Unknown user")
+		return
+	}
+	if err != nil {
+		log.Error("Failed to lookup user for bearer token", zap.Error(err))
+		errorResponse(w, r, CodeInternalError, "Authentication error", nil)
+		return
+	}
+
+	if user.Disabled {
+		log.Info("Disabled user attempted to authenticate", zap.String("username", user.Username))
+		a.authFailed(w, r, authFailureDisabledUser, CodeUserDisabled, "User account is disabled")
+		return
+	}
+
+	setAuditActor(r.Context(), user)
+	log = log.With(zap.String("user_id", user.ID))
+	log.Debug("User authenticated via JWT", zap.String("username", user.Username))
+
+	ctx := WithUser(r.Context(), user)
+	if identity.Scopes != nil {
+		ctx = WithTokenScopes(ctx, identity.Scopes)
+	}
+	ctx = WithRequestLogger(ctx, log)
+	r = r.WithContext(ctx)
+	next.ServeHTTP(w, r)
+}
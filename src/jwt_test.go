@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/gorilla/mux"
+)
+
+const testJWTIssuer = "https://sso.example.com"
+
+// newTestSigner creates an ES256 signer for a freshly generated key along
+// with the public key set that verifies it
+func newTestSigner(t *testing.T, kid string) (jose.Signer, jose.JSONWebKeySet) {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: private, KeyID: kid}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &private.PublicKey, KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"},
+	}}
+	return signer, keys
+}
+
+// signTestToken signs claims along with any extra claim maps
+func signTestToken(t *testing.T, signer jose.Signer, claims jwt.Claims, extra ...interface{}) string {
+	t.Helper()
+
+	builder := jwt.Signed(signer).Claims(claims)
+	for _, e := range extra {
+		builder = builder.Claims(e)
+	}
+	token, err := builder.Serialize()
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	signer, keys := newTestSigner(t, "test-key")
+	otherSigner, _ := newTestSigner(t, "test-key")
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	data, _ := json.Marshal(keys)
+	if err := os.WriteFile(jwksFile, data, 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	config := &Config{
+		APIKeyHeader:       "X-API-Key",
+		BootstrapAdminKey:  "test-admin-key-123",
+		JWTJWKSFile:        jwksFile,
+		JWTIssuer:          testJWTIssuer,
+		JWTAudience:        "calendar-api",
+		JWTUsernameClaim:   "sub",
+		JWTDefaultRole:     RoleMember,
+		JWTJWKSRefreshRate: time.Hour,
+	}
+
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config)
+	if err := authMiddleware.ReconcileBootstrapAdmin(context.Background()); err != nil {
+		t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+	}
+	userRepo.Create(context.Background(), &User{Username: "alice"}, nil)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(authMiddleware.RequireAuthentication)
+	api.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		user, _ := GetUser(r.Context())
+		w.Write([]byte(user.Username))
+	})
+	write := api.PathPrefix("/write").Subrouter()
+	write.Use(authMiddleware.RequireScope(ScopeEventsWrite))
+	write.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/events", nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	claimsFor := func(subject string) jwt.Claims {
+		now := time.Now()
+		return jwt.Claims{
+			Issuer:   testJWTIssuer,
+			Subject:  subject,
+			Audience: jwt.Audience{"calendar-api"},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		}
+	}
+
+	t.Run("API keys still work", func(t *testing.T) {
+		if w := serve("X-API-Key", "test-admin-key-123"); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Rejected tokens", func(t *testing.T) {
+		wrongIssuer := claimsFor("alice")
+		wrongIssuer.Issuer = "https://evil.example.com"
+
+		wrongAudience := claimsFor("alice")
+		wrongAudience.Audience = jwt.Audience{"other-api"}
+
+		expired := claimsFor("alice")
+		expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+		noExpiry := claimsFor("alice")
+		noExpiry.Expiry = nil
+
+		noAudience := claimsFor("alice")
+		noAudience.Audience = nil
+
+		tokens := map[string]string{
+			"malformed":      "not-a-jwt",
+			"wrong issuer":   signTestToken(t, signer, wrongIssuer),
+			"wrong audience": signTestToken(t, signer, wrongAudience),
+			"expired":        signTestToken(t, signer, expired),
+			"no expiry":      signTestToken(t, signer, noExpiry),
+			"no audience":    signTestToken(t, signer, noAudience),
+			"untrusted key":  signTestToken(t, otherSigner, claimsFor("alice")),
+			"unknown user":   signTestToken(t, signer, claimsFor("mallory")),
+		}
+		for name, token := range tokens {
+			if w := serve("Authorization", "Bearer "+token); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected status 401, got %d", name, w.Code)
+			}
+		}
+	})
+
+	t.Run("Tokens never sign in to existing accounts", func(t *testing.T) {
+		config.JWTAutoProvision = true
+		defer func() { config.JWTAutoProvision = false }()
+
+		// alice was created with an API key and admin by the bootstrap process
+		for _, subject := range []string{"alice", bootstrapAdminUsername} {
+			if w := serve("Authorization", "Bearer "+signTestToken(t, signer, claimsFor(subject))); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected status 401, got %d %s", subject, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("Auto-provisioning", func(t *testing.T) {
+		config.JWTAutoProvision = true
+		defer func() { config.JWTAutoProvision = false }()
+
+		w := serve("Authorization", "Bearer "+signTestToken(t, signer, claimsFor("bob")))
+		if w.Code != http.StatusOK || w.Body.String() != "bob" {
+			t.Fatalf("Expected bob to be provisioned, got %d %s", w.Code, w.Body.String())
+		}
+
+		bob, _ := userRepo.GetByIdentity(context.Background(), testJWTIssuer, "bob")
+		if bob == nil || bob.Role != RoleMember {
+			t.Errorf("Expected bob to be provisioned as a member, got %+v", bob)
+		}
+	})
+
+	t.Run("Provisioned identities keep working", func(t *testing.T) {
+		w := serve("Authorization", "Bearer "+signTestToken(t, signer, claimsFor("bob")))
+		if w.Code != http.StatusOK || w.Body.String() != "bob" {
+			t.Errorf("Expected bob to authenticate, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Token scopes", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			claims map[string]interface{}
+			status int
+		}{
+			{name: "No scope claim is limited by role", status: http.StatusOK},
+			{name: "Scope claim without the scope", claims: map[string]interface{}{"scope": "openid events:read"}, status: http.StatusForbidden},
+			{name: "Scope claim with the scope", claims: map[string]interface{}{"scope": "events:read events:write"}, status: http.StatusOK},
+			{name: "Empty scope claim", claims: map[string]interface{}{"scope": ""}, status: http.StatusForbidden},
+			{name: "scp array", claims: map[string]interface{}{"scp": []string{"events:write"}}, status: http.StatusOK},
+			{name: "scp string", claims: map[string]interface{}{"scp": "events:read"}, status: http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var extra []interface{}
+				if tt.claims != nil {
+					extra = append(extra, tt.claims)
+				}
+				req := httptest.NewRequest("POST", "/api/write", nil)
+				req.Header.Set("Authorization", "Bearer "+signTestToken(t, signer, claimsFor("bob"), extra...))
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				if w.Code != tt.status {
+					t.Fatalf("Expected status %d, got %d %s", tt.status, w.Code, w.Body.String())
+				}
+				if tt.status == http.StatusForbidden && !strings.Contains(w.Body.String(), `"missing_scope":"events:write"`) {
+					t.Errorf("Expected the missing scope in the details, got %s", w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("Disabled user", func(t *testing.T) {
+		bob, _ := userRepo.GetByIdentity(context.Background(), testJWTIssuer, "bob")
+		userRepo.SetDisabled(context.Background(), bob.ID, true)
+		defer userRepo.SetDisabled(context.Background(), bob.ID, false)
+
+		if w := serve("Authorization", "Bearer "+signTestToken(t, signer, claimsFor("bob"))); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log output formats
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// logger is the application logger. It logs JSON at info level until main
+// replaces it with one built from the configuration.
+var logger = mustNewLogger(zapcore.InfoLevel.String(), LogFormatJSON)
+
+// NewLogger builds a logger writing to stderr at the given level ("debug",
+// "info", "warn" or "error") and in the given format ("json" or "console")
+func NewLogger(level, format string) (*zap.Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	var config zap.Config
+	switch format {
+	case LogFormatJSON:
+		config = zap.NewProductionConfig()
+	case LogFormatConsole:
+		config = zap.NewDevelopmentConfig()
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	config.Level = zap.NewAtomicLevelAt(lvl)
+	config.EncoderConfig.TimeKey = "timestamp"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	config.EncoderConfig.MessageKey = "message"
+	config.EncoderConfig.LevelKey = "level"
+	config.EncoderConfig.NameKey = "logger"
+	config.EncoderConfig.CallerKey = "caller"
+	config.EncoderConfig.StacktraceKey = "stacktrace"
+
+	return config.Build()
+}
+
+func mustNewLogger(level, format string) *zap.Logger {
+	l, err := NewLogger(level, format)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+const (
+	requestIDContextKey     contextKey = "request_id"
+	requestLoggerContextKey contextKey = "request_logger"
+)
+
+// WithRequestLogger adds a request-scoped logger to the context
+func WithRequestLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, requestLoggerContextKey, l)
+}
+
+// LoggerFromContext returns the request-scoped logger, or the application
+// logger outside of a request
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(requestLoggerContextKey).(*zap.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// GetRequestID returns the ID of the current request, if any
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// RequestIDMiddleware accepts a well-formed X-Request-ID from the client or
+// generates one, echoes it in the response and stores it in the context
+// together with a logger that tags every line with it
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		fields := append([]zap.Field{zap.String("request_id", id)}, traceFields(r.Context())...)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		ctx = WithRequestLogger(ctx, logger.With(fields...))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is short and
+// made of printable ASCII, so that it is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// LoggingMiddleware logs every completed request with the request logger
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		path := r.URL.Path
+		method := r.Method
+
+		// Create a custom response writer to capture the status code
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(rw, r)
+
+		// Log request details
+		duration := time.Since(start)
+		LoggerFromContext(r.Context()).Info("HTTP request completed",
+			zap.String("method", method),
+			zap.String("path", path),
+			zap.Int("status", rw.statusCode),
+			zap.Duration("duration", duration),
+			zap.String("client_ip", clientIP(r)),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+		)
+	})
+}
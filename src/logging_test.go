@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	r := mux.NewRouter()
+	r.Use(RequestIDMiddleware)
+	r.Use(LoggingMiddleware)
+	r.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("handling request")
+		errorResponse(w, r, CodeInvalidRequestBody, "Bad request", nil)
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+		keep      bool
+	}{
+		{name: "Generate when missing", requestID: "", keep: false},
+		{name: "Accept client ID", requestID: "client-req-42", keep: true},
+		{name: "Replace ID with whitespace", requestID: "bad id", keep: false},
+		{name: "Replace overlong ID", requestID: strings.Repeat("a", maxRequestIDLength+1), keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+
+			req := httptest.NewRequest("GET", "/fail", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.keep && id != tt.requestID {
+				t.Fatalf("Expected request ID %q, got %q", tt.requestID, id)
+			}
+			if !tt.keep {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Fatalf("Expected generated UUID request ID, got %q", id)
+				}
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.RequestID != id {
+				t.Errorf("Expected request ID %q in error response, got %q", id, response.RequestID)
+			}
+
+			entries := logs.All()
+			if len(entries) != 2 {
+				t.Fatalf("Expected 2 log entries, got %d", len(entries))
+			}
+			for _, entry := range entries {
+				if got := entry.ContextMap()["request_id"]; got != id {
+					t.Errorf("Expected %q to be logged with request ID %q, got %v", entry.Message, id, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmatchedRequestsAreLogged(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	r, _ := setupSpecTest(t)
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: "GET", path: "/nonexistent", status: http.StatusNotFound},
+		{method: "PATCH", path: "/health", status: http.StatusMethodNotAllowed},
+	} {
+		logs.TakeAll()
+
+		// The test peer 192.0.2.1 is a trusted proxy
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+
+		completed := logs.FilterMessage("HTTP request completed").All()
+		if len(completed) != 1 {
+			t.Fatalf("%s %s: expected the request to be logged once, got %d entries", tt.method, tt.path, len(completed))
+		}
+		fields := completed[0].ContextMap()
+		if fields["status"] != int64(tt.status) || fields["client_ip"] != "203.0.113.9" {
+			t.Errorf("%s %s: unexpected log fields %v", tt.method, tt.path, fields)
+		}
+	}
+}
+
+func TestLoggerFromContextFallsBack(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if LoggerFromContext(req.Context()) != logger {
+		t.Error("Expected the application logger outside of a request")
+	}
+	if id := GetRequestID(req.Context()); id != "" {
+		t.Errorf("Expected no request ID, got %q", id)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	for _, format := range []string{LogFormatJSON, LogFormatConsole} {
+		l, err := NewLogger("warn", format)
+		if err != nil {
+			t.Fatalf("Failed to build %s logger: %v", format, err)
+		}
+		if l.Core().Enabled(zapcore.InfoLevel) || !l.Core().Enabled(zapcore.WarnLevel) {
+			t.Errorf("Expected %s logger to log at warn level", format)
+		}
+	}
+
+	if _, err := NewLogger("verbose", LogFormatJSON); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := NewLogger("info", "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
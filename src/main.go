@@ -2,64 +2,71 @@ package main
 
 import (
 	"context"
-	"log"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/YoloWingPixie/calendar-api/docs"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
-var (
-	logger *zap.Logger
-)
-
-func init() {
-	// Configure structured JSON logging
-	config := zap.NewProductionConfig()
-	config.EncoderConfig.TimeKey = "timestamp"
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	config.EncoderConfig.MessageKey = "message"
-	config.EncoderConfig.LevelKey = "level"
-	config.EncoderConfig.NameKey = "logger"
-	config.EncoderConfig.CallerKey = "caller"
-	config.EncoderConfig.StacktraceKey = "stacktrace"
-
-	var err error
-	logger, err = config.Build()
-	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer func() {
-		_ = logger.Sync() // Ignore sync errors on stderr/stdout
-	}()
-}
+// serverWriteTimeout bounds how long the server spends writing a response
+const serverWriteTimeout = 15 * time.Second
 
 func main() {
-	log.Println("🚀 Starting Calendar API...")
-	log.Printf("📋 %s", GetVersionInfo())
+	logger.Info("Starting Calendar API",
+		zap.String("version", Version),
+		zap.String("commit", Commit),
+		zap.String("built", Date),
+		zap.String("go_version", GoVersion),
+	)
 
 	// Load environment variables from .env file (for local development)
 	if err := godotenv.Load(); err != nil {
-		log.Println("📝 No .env file found, using environment variables")
+		logger.Info("No .env file found, using environment variables")
 	}
 
 	// Load configuration from Doppler secrets or environment variables
 	config, err := LoadConfig()
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	// Replace the startup logger with the configured one, and route output
+	// of the standard library logger through it
+	configuredLogger, err := NewLogger(config.LogLevel, config.LogFormat)
+	if err != nil {
+		logger.Fatal("Failed to initialize logger", zap.Error(err))
+	}
+	logger = configuredLogger
+	defer func() {
+		_ = logger.Sync() // Ignore sync errors on stderr/stdout
+	}()
+	zap.RedirectStdLog(logger)
+
+	// Initialize tracing before anything that creates spans
+	shutdownTracing, err := InitTracing(context.Background(), config)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
 
 	// Initialize database connection
 	db, err := NewDB(config)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 	defer db.Close()
 
@@ -68,47 +75,134 @@ func main() {
 
 	// Run pending migrations
 	if err := migrationManager.RunMigrations(); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		logger.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
 	// Initialize repositories
 	userRepo := NewUserRepository(db)
+	apiKeyRepo := NewAPIKeyRepository(db)
 	eventRepo := NewEventRepository(db)
+	idempotencyRepo := NewIdempotencyRepository(db)
+	auditRepo := NewAuditRepository(db)
+
+	// Initialize authentication middleware. Failed attempts are shared
+	// between instances unless the in-memory store is configured.
+	var authFailureStore AuthFailureStore = NewPostgresAuthFailureStore(db)
+	if config.AuthFailureStore == AuthFailureStoreMemory {
+		authFailureStore = NewMemoryAuthFailureStore()
+	}
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(authFailureStore, config), config)
+
+	// Create or update the bootstrap admin user if needed
+	if err := authMiddleware.ReconcileBootstrapAdmin(context.Background()); err != nil {
+		logger.Fatal("Failed to reconcile bootstrap admin user", zap.Error(err))
+	}
 
-	// Initialize authentication middleware
-	authMiddleware := NewAuthMiddleware(userRepo, config)
+	// Initialize idempotency middleware for retry-safe creates
+	idempotencyMiddleware := NewIdempotencyMiddleware(idempotencyRepo, config)
 
-	// Create bootstrap admin user if needed
-	if err := authMiddleware.CreateBootstrapUser(); err != nil {
-		log.Fatal("Failed to create bootstrap user:", err)
+	// Initialize rate limiting
+	var rateLimiter *RateLimitMiddleware
+	if config.RateLimitEnabled {
+		var store RateLimitStore = NewMemoryRateLimitStore()
+		if config.RateLimitStore == RateLimitStorePostgres {
+			store = NewPostgresRateLimitStore(db)
+		}
+		rateLimiter = NewRateLimitMiddleware(store, config)
 	}
 
 	// Initialize handlers
 	eventHandler := NewEventHandler(eventRepo)
+	batchHandler := NewBatchHandler(eventRepo, config)
+	userHandler := NewUserHandler(userRepo)
+	apiKeyHandler := NewAPIKeyHandler(apiKeyRepo, userRepo, config)
+	lockoutHandler := NewAuthLockoutHandler(authMiddleware.failures)
+	auditHandler := NewAuditHandler(auditRepo)
+
+	// Initialize dependency health checks
+	healthChecker := NewHealthChecker()
+	healthChecker.Register(HealthCheckDatabase, DatabasePingCheck(eventRepo.Ping, pingCacheTTL))
+	healthChecker.Register(HealthCheckPool, DatabasePoolCheck(db.Stats, poolSaturationGrace))
+	healthChecker.Register(HealthCheckMigrations, MigrationCheck(migrationManager.GetMigrationStatus))
+	healthHandler := NewHealthHandler(healthChecker)
+
+	swaggerUI, err := fs.Sub(docs.SwaggerUI, "swagger-ui")
+	if err != nil {
+		logger.Fatal("Failed to load Swagger UI assets", zap.Error(err))
+	}
 
-	// Initialize router
-	r := mux.NewRouter()
+	openAPIHandler, err := NewOpenAPIHandler(docs.OpenAPI, swaggerUI, GetVersion())
+	if err != nil {
+		logger.Fatal("Failed to load OpenAPI document", zap.Error(err))
+	}
 
-	// Public routes (no authentication required)
-	r.HandleFunc("/health", eventHandler.HealthCheck).Methods("GET")
-	r.HandleFunc("/version", eventHandler.VersionCheck).Methods("GET")
+	// Responses are only checked outside production, where the cost of
+	// buffering every response body is acceptable
+	openAPIValidator, err := NewOpenAPIValidator(docs.OpenAPI, config.Environment != "production")
+	if err != nil {
+		logger.Fatal("Failed to load OpenAPI document", zap.Error(err))
+	}
 
-	// Protected API routes (require authentication)
-	api := r.PathPrefix("/api").Subrouter()
-	api.Use(authMiddleware.RequireAPIKey)
-	api.HandleFunc("/events", eventHandler.ListEvents).Methods("GET")
-	api.HandleFunc("/events", eventHandler.CreateEvent).Methods("POST")
-	api.HandleFunc("/events/{id}", eventHandler.GetEvent).Methods("GET")
-	api.HandleFunc("/events/{id}", eventHandler.UpdateEvent).Methods("PUT")
-	api.HandleFunc("/events/{id}", eventHandler.DeleteEvent).Methods("DELETE")
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
 
-	// Middleware
-	r.Use(LoggingMiddleware)
-	r.Use(MetricsMiddleware)
-	r.Use(CORSMiddleware)
+	go RunPeriodic(jobsCtx, config.IdempotencyCleanupInterval, "idempotency key cleanup", func() error {
+		deleted, err := idempotencyRepo.DeleteExpired(jobsCtx, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			logger.Info("Removed expired idempotency keys", zap.Int64("count", deleted))
+		}
+		return nil
+	})
 
-	// Add Prometheus metrics endpoint
-	r.Path("/metrics").Handler(promhttp.Handler())
+	go RunPeriodic(jobsCtx, config.TrashPurgeInterval, "trash retention purge", func() error {
+		purged, err := eventRepo.PurgeDeleted(jobsCtx, time.Now().UTC().Add(-config.TrashRetention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			logger.Info("Purged events from the trash", zap.Int64("count", purged))
+		}
+		return nil
+	})
+
+	go RunPeriodic(jobsCtx, AuthFailurePruneInterval, "auth failure pruning", func() error {
+		return authMiddleware.failures.Prune(jobsCtx, time.Now())
+	})
+
+	if rateLimiter != nil {
+		go RunPeriodic(jobsCtx, RateLimitPruneInterval, "rate limit pruning", func() error {
+			return rateLimiter.store.Prune(jobsCtx, time.Now())
+		})
+	}
+
+	// Business gauges are queried on scrape
+	prometheus.MustRegister(NewBusinessMetricsCollector(NewStatsRepository(db), config.MetricsCacheTTL))
+
+	// Validated with the rest of the configuration
+	trustedProxies, _ := ParseTrustedProxies(config.TrustedProxies)
+
+	r := NewRouter(Routes{
+		Auth:           authMiddleware,
+		Audit:          NewAuditMiddleware(auditRepo),
+		Idempotency:    idempotencyMiddleware,
+		RateLimiter:    rateLimiter,
+		Validator:      openAPIValidator,
+		MaxBodyBytes:   int64(config.MaxRequestBodyBytes),
+		TrustedProxies: trustedProxies,
+		Health:         healthHandler,
+		OpenAPI:        openAPIHandler,
+		Events:         eventHandler,
+		Batch:          batchHandler,
+		Users:          userHandler,
+		APIKeys:        apiKeyHandler,
+		Lockouts:       lockoutHandler,
+		AuditLog:       auditHandler,
+		Metrics:        promhttp.Handler(),
+	})
 
 	// Server configuration
 	addr := config.Host + ":" + config.Port
@@ -116,20 +210,22 @@ func main() {
 		Addr:         addr,
 		Handler:      r,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: serverWriteTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("🌐 Starting server on %s (environment: %s)", addr, config.Environment)
-		if config.BootstrapAdminKey != "" {
-			log.Printf("🔑 Bootstrap admin key is configured for API access")
-		}
-		log.Printf("🔒 API endpoints require authentication via %s header", config.APIKeyHeader)
+		logger.Info("Starting server",
+			zap.String("addr", addr),
+			zap.String("environment", config.Environment),
+			zap.String("api_key_header", config.APIKeyHeader),
+			zap.Bool("bootstrap_admin_key", config.BootstrapAdminKey != ""),
+			zap.Bool("jwt", config.JWTEnabled()),
+		)
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server:", err)
+			logger.Fatal("Failed to start server", zap.Error(err))
 		}
 	}()
 
@@ -137,52 +233,26 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server")
+	stopJobs()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
-	log.Println("Server exited")
-}
-
-// Middleware functions
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		path := r.URL.Path
-		method := r.Method
-
-		// Create a custom response writer to capture the status code
-		rw := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
-
-		next.ServeHTTP(rw, r)
-
-		// Log request details
-		duration := time.Since(start)
-		logger.Info("HTTP request completed",
-			zap.String("method", method),
-			zap.String("path", path),
-			zap.Int("status", rw.statusCode),
-			zap.Duration("duration", duration),
-			zap.String("remote_addr", r.RemoteAddr),
-			zap.String("user_agent", r.UserAgent()),
-		)
-	})
+	logger.Info("Server exited")
 }
 
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID, traceparent, tracestate")
+		w.Header().Set("Access-Control-Expose-Headers", "RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -2,16 +2,20 @@ package main
 
 import (
 	"net/http"
-	"runtime"
 	"strconv"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// unmatchedRouteLabel is the path label of requests that match no route, so
+// that arbitrary paths do not create new time series
+const unmatchedRouteLabel = "unmatched"
+
 var (
-	// HTTP metrics
+	// HTTP metrics, labelled by route template rather than raw path
 	httpRequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
@@ -29,6 +33,22 @@ var (
 		[]string{"method", "path"},
 	)
 
+	httpResponseSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies in bytes",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+		},
+		[]string{"method", "path"},
+	)
+
+	httpRequestsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		},
+	)
+
 	// Database metrics
 	dbOperationsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -47,51 +67,63 @@ var (
 		[]string{"operation", "table"},
 	)
 
-	// Business metrics
-	eventsCreatedTotal = promauto.NewCounter(
+	// Rate limiting metrics
+	rateLimitedRequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "events_created_total",
-			Help: "Total number of events created",
+			Name: "rate_limited_requests_total",
+			Help: "Total number of requests rejected by the rate limiter",
 		},
+		[]string{"role"},
 	)
 
-	eventsDeletedTotal = promauto.NewCounter(
+	authFailuresTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "events_deleted_total",
-			Help: "Total number of events deleted",
+			Name: "auth_failures_total",
+			Help: "Total number of failed authentication attempts by reason",
 		},
+		[]string{"reason"},
 	)
 
-	activeEvents = promauto.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "active_events",
-			Help: "Number of active events in the system",
+	// OpenAPI metrics
+	openAPIResponseViolationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openapi_response_violations_total",
+			Help: "Total number of responses that do not match the OpenAPI document",
 		},
+		[]string{"method", "path"},
 	)
 
-	// System metrics
-	goroutines = promauto.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "goroutines",
-			Help: "Number of goroutines",
+	// Business metrics
+	eventsCreatedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "events_created_total",
+			Help: "Total number of events created",
 		},
 	)
 
-	memoryAlloc = promauto.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "memory_alloc_bytes",
-			Help: "Allocated memory in bytes",
+	eventsDeletedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "events_deleted_total",
+			Help: "Total number of events deleted",
 		},
 	)
+
+	// Gauges of current totals are exported by BusinessMetricsCollector, and
+	// Go runtime and process metrics by the collectors of the default registry
 )
 
-// MetricsMiddleware adds Prometheus metrics to HTTP handlers
+// MetricsMiddleware adds Prometheus metrics to HTTP handlers. Router
+// middleware only runs for matched routes, so the router's NotFoundHandler
+// and MethodNotAllowedHandler should be wrapped as well.
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		path := r.URL.Path
+		path := routeLabel(r)
 		method := r.Method
 
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
 		// Create a custom response writer to capture the status code
 		rw := &responseWriter{
 			ResponseWriter: w,
@@ -104,19 +136,23 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start).Seconds()
 		httpRequestsTotal.WithLabelValues(method, path, strconv.Itoa(rw.statusCode)).Inc()
 		httpRequestDuration.WithLabelValues(method, path).Observe(duration)
+		httpResponseSize.WithLabelValues(method, path).Observe(float64(rw.size))
 	})
 }
 
+// routeLabel returns the template of the route matched by r, such as
+// "/api/events/{id}", or unmatchedRouteLabel
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return unmatchedRouteLabel
+}
+
 // RecordDBOperation records database operation metrics
 func RecordDBOperation(operation, table string, duration time.Duration) {
 	dbOperationsTotal.WithLabelValues(operation, table).Inc()
 	dbOperationDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
 }
-
-// UpdateSystemMetrics updates system-level metrics
-func UpdateSystemMetrics() {
-	goroutines.Set(float64(runtime.NumGoroutine()))
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	memoryAlloc.Set(float64(m.Alloc))
-}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestMetricsMiddleware(t *testing.T) {
@@ -65,6 +66,34 @@ func TestMetricsMiddleware(t *testing.T) {
 	}
 }
 
+func TestMetricsRouteLabels(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.NotFoundHandler = MetricsMiddleware(http.NotFoundHandler())
+	r.HandleFunc("/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"` + mux.Vars(r)["id"] + `"}`))
+	}).Methods("GET")
+
+	routeRequests := httpRequestsTotal.WithLabelValues("GET", "/events/{id}", "200")
+	unmatchedRequests := httpRequestsTotal.WithLabelValues("GET", unmatchedRouteLabel, "404")
+	routeBefore := testutil.ToFloat64(routeRequests)
+	unmatchedBefore := testutil.ToFloat64(unmatchedRequests)
+
+	for _, path := range []string{"/events/a", "/events/b", "/nonexistent/1", "/nonexistent/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if delta := testutil.ToFloat64(routeRequests) - routeBefore; delta != 2 {
+		t.Errorf("Expected 2 requests labelled with the route template, got %v", delta)
+	}
+	if delta := testutil.ToFloat64(unmatchedRequests) - unmatchedBefore; delta != 2 {
+		t.Errorf("Expected 2 unmatched requests, got %v", delta)
+	}
+	if value := testutil.ToFloat64(httpRequestsInFlight); value != 0 {
+		t.Errorf("Expected no requests in flight, got %v", value)
+	}
+}
+
 func TestRecordDBOperation(t *testing.T) {
 	// Reset Prometheus metrics before test
 	prometheus.DefaultRegisterer = prometheus.NewRegistry()
@@ -105,17 +134,6 @@ func TestRecordDBOperation(t *testing.T) {
 	}
 }
 
-func TestUpdateSystemMetrics(t *testing.T) {
-	// Test that UpdateSystemMetrics doesn't panic
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("UpdateSystemMetrics panicked: %v", r)
-		}
-	}()
-
-	UpdateSystemMetrics()
-}
-
 func TestLoggingMiddleware(t *testing.T) {
 	// Create a test router with logging middleware
 	r := mux.NewRouter()
@@ -3,10 +3,11 @@ package main
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 // Migration represents a database migration
@@ -97,16 +98,282 @@ func (m *MigrationManager) GetMigrations() []Migration {
 				('550e8400-e29b-41d4-a716-446655440005', 'End of Day Sync', 'Quick sync to wrap up the day', '2025-06-13 17:00:00+00', '2025-06-13 17:15:00+00');
 			`,
 		},
+		{
+			Version:     "006",
+			Description: "Create idempotency_keys table",
+			SQL: `
+			CREATE TABLE IF NOT EXISTS idempotency_keys (
+				user_id VARCHAR(36) NOT NULL,
+				idempotency_key VARCHAR(255) NOT NULL,
+				request_hash VARCHAR(64) NOT NULL,
+				status_code INTEGER NOT NULL DEFAULT 0,
+				content_type VARCHAR(255) NOT NULL DEFAULT '',
+				response_body BYTEA,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				PRIMARY KEY (user_id, idempotency_key)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+			`,
+		},
+		{
+			Version:     "007",
+			Description: "Add deleted_at column to events table for soft delete",
+			SQL: `
+			ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+			CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events(deleted_at) WHERE deleted_at IS NOT NULL;
+			`,
+		},
+		{
+			Version:     "008",
+			Description: "Create event_revisions table",
+			SQL: `
+			CREATE TABLE IF NOT EXISTS event_revisions (
+				id VARCHAR(36) PRIMARY KEY,
+				event_id VARCHAR(36) NOT NULL,
+				revision INTEGER NOT NULL,
+				action VARCHAR(20) NOT NULL,
+				snapshot JSONB NOT NULL,
+				actor_id VARCHAR(36) NOT NULL,
+				actor_username VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				UNIQUE (event_id, revision)
+			);
+			`,
+		},
+		{
+			Version:     "009",
+			Description: "Add disabled flag to users table",
+			SQL: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+			`,
+		},
+		{
+			Version:     "010",
+			Description: "Replace plaintext API keys with prefix and SHA-256 hash",
+			SQL: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS api_key_prefix VARCHAR(12);
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS api_key_hash VARCHAR(64);
+
+			-- Hash existing keys in place so current clients keep working
+			UPDATE users
+			SET api_key_prefix = LEFT(api_key, 12),
+				api_key_hash = encode(sha256(convert_to(api_key, 'UTF8')), 'hex');
+
+			ALTER TABLE users ALTER COLUMN api_key_prefix SET NOT NULL;
+			ALTER TABLE users ALTER COLUMN api_key_hash SET NOT NULL;
+			ALTER TABLE users DROP COLUMN api_key;
+
+			CREATE INDEX IF NOT EXISTS idx_users_api_key_prefix ON users(api_key_prefix);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_users_api_key_hash ON users(api_key_hash);
+			`,
+		},
+		{
+			Version:     "011",
+			Description: "Move API keys to api_keys table with names, expiry and revocation",
+			SQL: `
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id VARCHAR(36) PRIMARY KEY,
+				user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				key_prefix VARCHAR(12) NOT NULL,
+				key_hash VARCHAR(64) UNIQUE NOT NULL,
+				expires_at TIMESTAMP WITH TIME ZONE,
+				last_used_at TIMESTAMP WITH TIME ZONE,
+				revoked_at TIMESTAMP WITH TIME ZONE,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_api_keys_key_prefix ON api_keys(key_prefix);
+			CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+			-- Carry every existing key over as the user's "default" key
+			INSERT INTO api_keys (id, user_id, name, key_prefix, key_hash, created_at)
+			SELECT gen_random_uuid()::text, id, 'default', api_key_prefix, api_key_hash, created_at
+			FROM users;
+
+			ALTER TABLE users DROP COLUMN api_key_prefix;
+			ALTER TABLE users DROP COLUMN api_key_hash;
+			`,
+		},
+		{
+			Version:     "012",
+			Description: "Add scopes to API keys",
+			SQL: `
+			-- Existing keys keep full access
+			ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT ARRAY['*'];
+			`,
+		},
+		{
+			Version:     "013",
+			Description: "Add role to users",
+			SQL: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member'
+				CHECK (role IN ('admin', 'member', 'service'));
+			`,
+		},
+		{
+			Version:     "014",
+			Description: "Create rate_limits table for shared rate limit counters",
+			SQL: `
+			-- Counters are short-lived, so skip write-ahead logging
+			CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+				key VARCHAR(255) NOT NULL,
+				window_start TIMESTAMP WITH TIME ZONE NOT NULL,
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				count INTEGER NOT NULL,
+				PRIMARY KEY (key, window_start)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_rate_limits_expires_at ON rate_limits(expires_at);
+			`,
+		},
+		{
+			Version:     "015",
+			Description: "Create append-only audit_log table",
+			SQL: `
+			-- Actors are stored by value so entries outlive deleted users
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id VARCHAR(36) PRIMARY KEY,
+				occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				actor_id VARCHAR(36) NOT NULL DEFAULT '',
+				actor_username VARCHAR(100) NOT NULL DEFAULT '',
+				method VARCHAR(10) NOT NULL,
+				route VARCHAR(255) NOT NULL,
+				path TEXT NOT NULL,
+				resource VARCHAR(50) NOT NULL,
+				target_ids JSONB NOT NULL DEFAULT '{}',
+				request_id VARCHAR(255) NOT NULL DEFAULT '',
+				source_ip VARCHAR(45) NOT NULL,
+				status INTEGER NOT NULL,
+				outcome VARCHAR(10) NOT NULL CHECK (outcome IN ('success', 'failure'))
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log(occurred_at);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id, occurred_at);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_actor_username ON audit_log(actor_username, occurred_at);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource, occurred_at);
+
+			-- Reject changes to recorded entries
+			CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_log is append-only';
+			END;
+			$$ LANGUAGE plpgsql;
+
+			DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+			CREATE TRIGGER audit_log_append_only
+				BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+				FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+			`,
+		},
+		{
+			Version:     "016",
+			Description: "Un-escape HTML-escaped event titles and descriptions",
+			SQL: `
+			-- Titles and descriptions were stored HTML-escaped, with "<" and ">"
+			-- escaped before "&" so that "<" became "&amp;lt;". Undo each
+			-- replacement in reverse order.
+			CREATE OR REPLACE FUNCTION unescape_stored_text(value TEXT) RETURNS TEXT AS $$
+				SELECT REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(value,
+					'&#39;', ''''),
+					'&quot;', '"'),
+					'&amp;', '&'),
+					'&gt;', '>'),
+					'&lt;', '<');
+			$$ LANGUAGE sql IMMUTABLE;
+
+			UPDATE events
+			SET title = unescape_stored_text(title),
+				description = unescape_stored_text(description)
+			WHERE title ~ '&(amp|quot|#39);' OR description ~ '&(amp|quot|#39);';
+
+			UPDATE event_revisions
+			SET snapshot = jsonb_set(snapshot, '{title}', to_jsonb(unescape_stored_text(snapshot->>'title')))
+			WHERE jsonb_typeof(snapshot->'title') = 'string' AND snapshot->>'title' ~ '&(amp|quot|#39);';
+
+			UPDATE event_revisions
+			SET snapshot = jsonb_set(snapshot, '{description}', to_jsonb(unescape_stored_text(snapshot->>'description')))
+			WHERE jsonb_typeof(snapshot->'description') = 'string' AND snapshot->>'description' ~ '&(amp|quot|#39);';
+
+			DROP FUNCTION unescape_stored_text(TEXT);
+			`,
+		},
+		{
+			Version:     "017",
+			Description: "Create user_identities table binding JWT issuers and subjects to users",
+			SQL: `
+			CREATE TABLE IF NOT EXISTS user_identities (
+				issuer VARCHAR(255) NOT NULL,
+				subject VARCHAR(255) NOT NULL,
+				user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (issuer, subject)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+			`,
+		},
+		{
+			Version:     "018",
+			Description: "Create auth_failures table for shared brute-force lockouts",
+			SQL: `
+			CREATE TABLE IF NOT EXISTS auth_failures (
+				ip VARCHAR(64) PRIMARY KEY,
+				failures INTEGER NOT NULL,
+				last_failure TIMESTAMP WITH TIME ZONE NOT NULL,
+				locked_until TIMESTAMP WITH TIME ZONE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_auth_failures_last_failure ON auth_failures(last_failure);
+			`,
+		},
+		{
+			Version:     "019",
+			Description: "Flag the bootstrap admin user",
+			SQL: `
+			-- Existing deployments are flagged on the next startup, when the
+			-- holder of the configured bootstrap key is adopted
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS bootstrap_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_users_bootstrap_admin ON users(bootstrap_admin) WHERE bootstrap_admin;
+			`,
+		},
+		{
+			Version:     "020",
+			Description: "Backfill create revisions for events without history",
+			SQL: `
+			-- Events that predate revision history start it with a "create"
+			-- snapshot of their current state, attributed to no user
+			INSERT INTO event_revisions (id, event_id, revision, action, snapshot, actor_id, actor_username, created_at)
+			SELECT gen_random_uuid()::text, e.id, 1, 'create',
+				jsonb_strip_nulls(jsonb_build_object(
+					'id', e.id,
+					'title', e.title,
+					'description', e.description,
+					'start_time', e.start_time,
+					'end_time', e.end_time,
+					'created_at', e.created_at,
+					'updated_at', e.updated_at,
+					'deleted_at', e.deleted_at
+				)),
+				'', '', e.created_at
+			FROM events e
+			WHERE NOT EXISTS (SELECT 1 FROM event_revisions r WHERE r.event_id = e.id);
+			`,
+		},
 	}
 }
 
 // RunMigrations executes all pending migrations
 func (m *MigrationManager) RunMigrations() error {
-	log.Println("🔄 Starting database migrations...")
+	logger.Info("Starting database migrations")
 
 	migrations := m.GetMigrations()
 	if len(migrations) == 0 {
-		log.Println("✅ No migrations to run")
+		logger.Info("No migrations to run")
 		return nil
 	}
 
@@ -121,7 +388,7 @@ func (m *MigrationManager) RunMigrations() error {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
-	log.Printf("📊 Total migrations: %d, Applied: %d", len(migrations), len(appliedMigrations))
+	logger.Info("Found migrations", zap.Int("total", len(migrations)), zap.Int("applied", len(appliedMigrations)))
 
 	pendingCount := 0
 	for _, migration := range migrations {
@@ -131,14 +398,14 @@ func (m *MigrationManager) RunMigrations() error {
 				return fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
 			}
 		} else {
-			log.Printf("⏭️  Migration %s (%s) already applied", migration.Version, migration.Description)
+			logger.Debug("Migration already applied", zap.String("version", migration.Version), zap.String("description", migration.Description))
 		}
 	}
 
 	if pendingCount == 0 {
-		log.Println("✅ All migrations are up to date")
+		logger.Info("All migrations are up to date")
 	} else {
-		log.Printf("✅ Successfully applied %d pending migrations", pendingCount)
+		logger.Info("Applied pending migrations", zap.Int("count", pendingCount))
 	}
 
 	return nil
@@ -174,7 +441,11 @@ func (m *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
 		}
 
 		applied[version] = true
-		log.Printf("📋 Previously applied: %s (%s) at %s", version, description, appliedAt.Format(time.RFC3339))
+		logger.Debug("Previously applied migration",
+			zap.String("version", version),
+			zap.String("description", description),
+			zap.Time("applied_at", appliedAt),
+		)
 	}
 
 	return applied, nil
@@ -182,7 +453,7 @@ func (m *MigrationManager) getAppliedMigrations() (map[string]bool, error) {
 
 // applyMigration applies a single migration
 func (m *MigrationManager) applyMigration(migration Migration) error {
-	log.Printf("🚀 Applying migration %s: %s", migration.Version, migration.Description)
+	logger.Info("Applying migration", zap.String("version", migration.Version), zap.String("description", migration.Description))
 
 	// Start a transaction
 	tx, err := m.db.Begin()
@@ -191,7 +462,7 @@ func (m *MigrationManager) applyMigration(migration Migration) error {
 	}
 	defer func() {
 		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-			log.Printf("Warning: failed to rollback transaction: %v", err)
+			logger.Warn("Failed to rollback transaction", zap.Error(err))
 		}
 	}()
 
@@ -222,23 +493,22 @@ func (m *MigrationManager) applyMigration(migration Migration) error {
 		return fmt.Errorf("failed to commit migration transaction: %w", err)
 	}
 
-	log.Printf("✅ Migration %s completed successfully in %v", migration.Version, duration)
+	logger.Info("Migration completed", zap.String("version", migration.Version), zap.Duration("duration", duration))
 	return nil
 }
 
 // executeSQL executes SQL with proper error handling and logging
 func (m *MigrationManager) executeSQL(sql, version, description string) error {
-	log.Printf("🔧 Executing SQL for migration %s (%s)", version, description)
-
 	// Clean up the SQL for logging (remove extra whitespace)
 	cleanSQL := strings.TrimSpace(strings.ReplaceAll(sql, "\n", " "))
 	if len(cleanSQL) > 200 {
 		cleanSQL = cleanSQL[:200] + "..."
 	}
-	log.Printf("📝 SQL: %s", cleanSQL)
+	log := logger.With(zap.String("version", version), zap.String("description", description))
+	log.Debug("Executing migration SQL", zap.String("sql", cleanSQL))
 
 	if _, err := m.db.Exec(sql); err != nil {
-		log.Printf("❌ Failed to execute SQL for migration %s: %v", version, err)
+		log.Error("Failed to execute migration SQL", zap.Error(err))
 		return err
 	}
 
@@ -247,17 +517,16 @@ func (m *MigrationManager) executeSQL(sql, version, description string) error {
 
 // executeSQLInTx executes SQL within a transaction
 func (m *MigrationManager) executeSQLInTx(tx *sql.Tx, sql, version, description string) error {
-	log.Printf("🔧 Executing SQL for migration %s (%s) in transaction", version, description)
-
 	// Clean up the SQL for logging (remove extra whitespace)
 	cleanSQL := strings.TrimSpace(strings.ReplaceAll(sql, "\n", " "))
 	if len(cleanSQL) > 200 {
 		cleanSQL = cleanSQL[:200] + "..."
 	}
-	log.Printf("📝 SQL: %s", cleanSQL)
+	log := logger.With(zap.String("version", version), zap.String("description", description))
+	log.Debug("Executing migration SQL in transaction", zap.String("sql", cleanSQL))
 
 	if _, err := tx.Exec(sql); err != nil {
-		log.Printf("❌ Failed to execute SQL for migration %s: %v", version, err)
+		log.Error("Failed to execute migration SQL", zap.Error(err))
 		return err
 	}
 
@@ -290,21 +559,17 @@ func (m *MigrationManager) GetMigrationStatus() ([]Migration, error) {
 
 // PrintMigrationStatus prints a detailed status of all migrations
 func (m *MigrationManager) PrintMigrationStatus() error {
-	log.Println("📊 Migration Status Report")
-	log.Println("=" + strings.Repeat("=", 80))
-
 	migrations, err := m.GetMigrationStatus()
 	if err != nil {
 		return err
 	}
 
 	for _, migration := range migrations {
-		status := "❌ PENDING"
-		if migration.Applied {
-			status = "✅ APPLIED"
-		}
-
-		log.Printf("%s | %s | %s", status, migration.Version, migration.Description)
+		logger.Info("Migration status",
+			zap.String("version", migration.Version),
+			zap.String("description", migration.Description),
+			zap.Bool("applied", migration.Applied),
+		)
 	}
 
 	appliedCount := 0
@@ -314,8 +579,7 @@ func (m *MigrationManager) PrintMigrationStatus() error {
 		}
 	}
 
-	log.Println("=" + strings.Repeat("=", 80))
-	log.Printf("📈 Summary: %d/%d migrations applied", appliedCount, len(migrations))
+	logger.Info("Migration status summary", zap.Int("applied", appliedCount), zap.Int("total", len(migrations)))
 
 	return nil
 }
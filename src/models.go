@@ -6,13 +6,14 @@ import (
 
 // Event represents a calendar event
 type Event struct {
-	ID          string    `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	StartTime   time.Time `json:"start_time" db:"start_time"`
-	EndTime     time.Time `json:"end_time" db:"end_time"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          string     `json:"id" db:"id"`
+	Title       string     `json:"title" db:"title"`
+	Description *string    `json:"description,omitempty" db:"description"`
+	StartTime   time.Time  `json:"start_time" db:"start_time"`
+	EndTime     time.Time  `json:"end_time" db:"end_time"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 // CreateEventRequest represents the request payload for creating an event
@@ -33,16 +34,32 @@ type UpdateEventRequest struct {
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string            `json:"error"`
-	Message string            `json:"message"`
-	Details map[string]string `json:"details,omitempty"`
+	Error     string            `json:"error"`
+	Message   string            `json:"message"`
+	Code      ErrorCode         `json:"code,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
 }
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
+	Status    string              `json:"status"`
+	Timestamp time.Time           `json:"timestamp"`
+	Database  string              `json:"database"`
+	Checks    []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// LivenessResponse represents the liveness probe response
+type LivenessResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
-	Database  string    `json:"database"`
+}
+
+// ReadinessResponse represents the readiness probe response
+type ReadinessResponse struct {
+	Status    string            `json:"status"`
+	Timestamp time.Time         `json:"timestamp"`
+	Checks    map[string]string `json:"checks"`
 }
 
 // VersionResponse represents the version check response
@@ -57,3 +74,147 @@ type ListEventsResponse struct {
 	Events []Event `json:"events"`
 	Count  int     `json:"count"`
 }
+
+// CreateUserRequest represents the request payload for creating a user
+type CreateUserRequest struct {
+	Username string `json:"username" validate:"required,min=1,max=100"`
+	Role     string `json:"role,omitempty" validate:"omitempty,oneof=admin member service"`
+}
+
+// UpdateUserRequest represents the request payload for updating a user
+type UpdateUserRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin member service"`
+}
+
+// CreateUserResponse represents a newly created user. The API key is only
+// ever returned in this response.
+type CreateUserResponse struct {
+	User
+	APIKey string `json:"api_key"`
+}
+
+// ListUsersResponse represents the response for listing users
+type ListUsersResponse struct {
+	Users []User `json:"users"`
+	Count int    `json:"count"`
+}
+
+// CreateAPIKeyRequest represents the request payload for creating an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes,omitempty" validate:"omitempty,dive,oneof=* events:read events:write keys:manage users:admin"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// RotateAPIKeyRequest represents the optional request payload for rotating
+// an API key
+type RotateAPIKeyRequest struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse represents a newly issued API key. The plaintext key
+// is only ever returned in this response.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// RotateAPIKeyResponse represents the replacement key issued by a rotation
+// together with the previous key, which stays valid until its new expiry
+type RotateAPIKeyResponse struct {
+	CreateAPIKeyResponse
+	Previous APIKey `json:"previous"`
+}
+
+// ListAPIKeysResponse represents the response for listing a user's API keys
+type ListAPIKeysResponse struct {
+	Keys  []APIKey `json:"keys"`
+	Count int      `json:"count"`
+}
+
+// Revision actions
+const (
+	RevisionActionCreate  = "create"
+	RevisionActionUpdate  = "update"
+	RevisionActionDelete  = "delete"
+	RevisionActionRestore = "restore"
+	RevisionActionRevert  = "revert"
+)
+
+// EventRevision represents an immutable snapshot of an event after a change
+type EventRevision struct {
+	ID            string    `json:"id" db:"id"`
+	EventID       string    `json:"event_id" db:"event_id"`
+	Revision      int       `json:"revision" db:"revision"`
+	Action        string    `json:"action" db:"action"`
+	Snapshot      Event     `json:"snapshot" db:"snapshot"`
+	ActorID       string    `json:"actor_id" db:"actor_id"`
+	ActorUsername string    `json:"actor_username" db:"actor_username"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// ListRevisionsResponse represents the response for listing event revisions
+type ListRevisionsResponse struct {
+	Revisions []EventRevision `json:"revisions"`
+	Count     int             `json:"count"`
+}
+
+// RevisionFieldChange represents a single field that differs between two revisions
+type RevisionFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RevisionDiffResponse represents the differences between two revisions
+type RevisionDiffResponse struct {
+	EventID string                `json:"event_id"`
+	From    int                   `json:"from"`
+	To      int                   `json:"to"`
+	Changes []RevisionFieldChange `json:"changes"`
+}
+
+// Batch operation types
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// Batch execution modes
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+// BatchOperation represents a single operation within a batch request
+type BatchOperation struct {
+	Op    string              `json:"op" validate:"required,oneof=create update delete"`
+	ID    string              `json:"id,omitempty"`
+	Event *CreateEventRequest `json:"event,omitempty"`
+}
+
+// BatchRequest represents the request payload for POST /api/events:batch
+type BatchRequest struct {
+	Mode       string           `json:"mode,omitempty" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1"`
+}
+
+// BatchOperationResult represents the outcome of a single batch operation
+type BatchOperationResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Event  *Event `json:"event,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchResponse represents the response for a batch request
+type BatchResponse struct {
+	Mode       string                 `json:"mode"`
+	Succeeded  int                    `json:"succeeded"`
+	Failed     int                    `json:"failed"`
+	RolledBack bool                   `json:"rolled_back"`
+	Results    []BatchOperationResult `json:"results"`
+}
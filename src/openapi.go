@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// docsPage renders Swagger UI for the spec served at /openapi.json, using
+// the assets served under /docs/assets/
+var docsPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Calendar API Docs</title>
+  <link rel="stylesheet" href="/docs/assets/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/assets/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`)
+
+// OpenAPIHandler serves the OpenAPI document and the interactive docs page
+type OpenAPIHandler struct {
+	yaml   []byte
+	json   []byte
+	assets fs.FS
+}
+
+// NewOpenAPIHandler prepares the YAML and JSON forms of spec with
+// info.version set to version. The docs page loads its stylesheet and
+// script from assets.
+func NewOpenAPIHandler(spec []byte, assets fs.FS, version string) (*OpenAPIHandler, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := setInfoVersion(&doc, version); err != nil {
+		return nil, err
+	}
+
+	var yamlOut bytes.Buffer
+	encoder := yaml.NewEncoder(&yamlOut)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI document as YAML: %w", err)
+	}
+
+	var value interface{}
+	if err := doc.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAPI document: %w", err)
+	}
+	jsonOut, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI document as JSON: %w", err)
+	}
+
+	return &OpenAPIHandler{yaml: yamlOut.Bytes(), json: jsonOut, assets: assets}, nil
+}
+
+// setInfoVersion replaces the value of info.version in a parsed document
+func setInfoVersion(doc *yaml.Node, version string) error {
+	if len(doc.Content) == 0 {
+		return fmt.Errorf("OpenAPI document is empty")
+	}
+
+	info := mappingValue(doc.Content[0], "info")
+	if info == nil {
+		return fmt.Errorf("OpenAPI document has no info object")
+	}
+
+	if node := mappingValue(info, "version"); node != nil {
+		node.Value = version
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+		return nil
+	}
+
+	info.Content = append(info.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "version"},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version, Style: yaml.DoubleQuotedStyle},
+	)
+	return nil
+}
+
+// mappingValue returns the value node for key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// YAML handles GET /openapi.yaml
+func (h *OpenAPIHandler) YAML(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(h.yaml)
+}
+
+// JSON handles GET /openapi.json
+func (h *OpenAPIHandler) JSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(h.json)
+}
+
+// Docs handles GET /docs
+func (h *OpenAPIHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+// Asset handles GET /docs/assets/{file}
+func (h *OpenAPIHandler) Asset(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["file"]
+	content, err := fs.ReadFile(h.assets, name)
+	if err != nil {
+		errorResponse(w, r, CodeRouteNotFound, "No route matches "+r.URL.Path, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	_, _ = w.Write(content)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/YoloWingPixie/calendar-api/docs"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// docsAssets returns the embedded Swagger UI assets
+func docsAssets(t *testing.T) fs.FS {
+	assets, err := fs.Sub(docs.SwaggerUI, "swagger-ui")
+	if err != nil {
+		t.Fatalf("Failed to load Swagger UI assets: %v", err)
+	}
+	return assets
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	handler, err := NewOpenAPIHandler(docs.OpenAPI, docsAssets(t), "v1.2.3")
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI document: %v", err)
+	}
+
+	serve := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/", nil))
+		return w
+	}
+
+	type spec struct {
+		OpenAPI string `json:"openapi" yaml:"openapi"`
+		Info    struct {
+			Title   string `json:"title" yaml:"title"`
+			Version string `json:"version" yaml:"version"`
+		} `json:"info" yaml:"info"`
+		Paths map[string]interface{} `json:"paths" yaml:"paths"`
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		w := serve(handler.YAML)
+		if ct := w.Header().Get("Content-Type"); ct != "application/yaml" {
+			t.Errorf("Expected application/yaml, got %s", ct)
+		}
+
+		var doc spec
+		if err := yaml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("Failed to parse YAML: %v", err)
+		}
+		if doc.Info.Version != "v1.2.3" || doc.Info.Title != "Calendar API" || len(doc.Paths) == 0 {
+			t.Errorf("Unexpected document: %+v", doc)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := serve(handler.JSON)
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected application/json, got %s", ct)
+		}
+
+		var doc spec
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("Failed to parse JSON: %v", err)
+		}
+		if doc.Info.Version != "v1.2.3" || !strings.HasPrefix(doc.OpenAPI, "3.") || len(doc.Paths) == 0 {
+			t.Errorf("Unexpected document: %+v", doc)
+		}
+	})
+
+	t.Run("Docs page", func(t *testing.T) {
+		w := serve(handler.Docs)
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("Expected HTML, got %s", w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), `url: "/openapi.json"`) {
+			t.Error("Expected docs page to load /openapi.json")
+		}
+		if strings.Contains(w.Body.String(), "https://") {
+			t.Error("Expected docs page to load its assets from the API, not a CDN")
+		}
+	})
+
+	t.Run("Docs assets", func(t *testing.T) {
+		asset := func(file string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest("GET", "/docs/assets/"+file, nil), map[string]string{"file": file})
+			handler.Asset(w, r)
+			return w
+		}
+
+		for file, contentType := range map[string]string{
+			"swagger-ui.css":       "text/css",
+			"swagger-ui-bundle.js": "text/javascript",
+		} {
+			w := asset(file)
+			if w.Code != http.StatusOK || w.Body.Len() == 0 {
+				t.Errorf("Expected %s to be served, got status %d", file, w.Code)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) {
+				t.Errorf("Expected %s for %s, got %s", contentType, file, w.Header().Get("Content-Type"))
+			}
+		}
+
+		if w := asset("missing.js"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown asset, got %d", w.Code)
+		}
+	})
+
+	t.Run("Numeric-looking versions stay strings", func(t *testing.T) {
+		handler, err := NewOpenAPIHandler([]byte("openapi: 3.0.3\ninfo:\n  title: T\n  version: 1.0.0\npaths: {}\n"), nil, "2.0")
+		if err != nil {
+			t.Fatalf("Failed to load document: %v", err)
+		}
+		var doc spec
+		if err := json.Unmarshal(serve(handler.JSON).Body.Bytes(), &doc); err != nil {
+			t.Fatalf("Failed to parse JSON: %v", err)
+		}
+		if doc.Info.Version != "2.0" {
+			t.Errorf("Expected version 2.0, got %q", doc.Info.Version)
+		}
+	})
+
+	t.Run("Invalid documents are rejected", func(t *testing.T) {
+		if _, err := NewOpenAPIHandler([]byte("openapi: 3.0.3\npaths: {}\n"), nil, "dev"); err == nil {
+			t.Error("Expected error for document without info")
+		}
+	})
+}
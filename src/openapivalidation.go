@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// routeVariablePattern matches mux path variables with a pattern, such as
+// "{revision:[0-9]+}", so that templates can be looked up in the spec
+var routeVariablePattern = regexp.MustCompile(`\{([^}:]+):[^}]+\}`)
+
+func init() {
+	// The docs page and its assets are served as HTML, CSS and JavaScript,
+	// and event listings may be streamed as JSON Lines, none of which
+	// openapi3filter has a decoder for
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/css", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/javascript", openapi3filter.PlainBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(NDJSONContentType, openapi3filter.PlainBodyDecoder)
+}
+
+// OpenAPIValidator checks requests, and optionally responses, against the
+// OpenAPI document
+type OpenAPIValidator struct {
+	doc               *openapi3.T
+	validateResponses bool
+}
+
+// NewOpenAPIValidator loads and validates spec. When validateResponses is
+// set, every response is also checked and violations are logged.
+func NewOpenAPIValidator(spec []byte, validateResponses bool) (*OpenAPIValidator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	return &OpenAPIValidator{doc: doc, validateResponses: validateResponses}, nil
+}
+
+// Operation returns the spec operation for a mux path template and method,
+// or nil when the spec does not describe it
+func (v *OpenAPIValidator) Operation(template, method string) (string, *openapi3.PathItem, *openapi3.Operation) {
+	path := routeVariablePattern.ReplaceAllString(template, "{$1}")
+	pathItem := v.doc.Paths.Find(path)
+	if pathItem == nil {
+		return path, nil, nil
+	}
+	return path, pathItem, pathItem.GetOperation(method)
+}
+
+// Middleware rejects requests that do not match the spec with 400 Bad
+// Request, or 415 Unsupported Media Type for bodies of a type the operation
+// does not accept. Requests to routes the spec does not describe are passed
+// through.
+func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template := routeLabel(r)
+		path, pathItem, operation := v.Operation(template, r.Method)
+		if operation == nil {
+			LoggerFromContext(r.Context()).Warn("Route is not described by the OpenAPI document",
+				zap.String("method", r.Method),
+				zap.String("route", template),
+			)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if body := operation.RequestBody; body != nil && r.ContentLength != 0 && body.Value.Content.Get(r.Header.Get("Content-Type")) == nil {
+			bodyErrorResponse(w, r, errUnsupportedMediaType)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: mux.Vars(r),
+			Route: &routers.Route{
+				Spec:      v.doc,
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    r.Method,
+				Operation: operation,
+			},
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			requestViolationResponse(w, r, err)
+			return
+		}
+
+		if !v.validateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &capturingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rw.statusCode,
+			Header:                 rw.Header(),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+		}
+		responseInput.SetBodyBytes(rw.body.Bytes())
+
+		if err := openapi3filter.ValidateResponse(r.Context(), responseInput); err != nil {
+			openAPIResponseViolationsTotal.WithLabelValues(r.Method, template).Inc()
+			LoggerFromContext(r.Context()).Warn("Response does not match the OpenAPI document",
+				zap.String("method", r.Method),
+				zap.String("route", template),
+				zap.Int("status", rw.statusCode),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+// requestViolationResponse writes a 400 response listing each violation by
+// parameter name, or by JSON pointer into the request body. Bodies over the
+// size limit are rejected with 413 instead.
+func requestViolationResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		bodyErrorResponse(w, r, maxBytesErr)
+		return
+	}
+
+	details := make(map[string]string)
+	collectRequestViolations(details, "", err)
+
+	errorDetailsResponse(w, r, CodeValidationFailed, "Request does not match the API specification", details, err)
+}
+
+// collectRequestViolations flattens the errors returned by
+// openapi3filter.ValidateRequest into field/reason pairs
+func collectRequestViolations(details map[string]string, field string, err error) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collectRequestViolations(details, field, inner)
+		}
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			field = e.Parameter.Name
+		case e.RequestBody != nil:
+			field = "body"
+		}
+		if e.Err == nil {
+			addViolation(details, field, e.Reason)
+			return
+		}
+		collectRequestViolations(details, field, e.Err)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 && field == "body" {
+			field = "/" + strings.Join(pointer, "/")
+		}
+		addViolation(details, field, e.Reason)
+	default:
+		addViolation(details, field, err.Error())
+	}
+}
+
+// addViolation records reason for field, joining reasons for the same field
+func addViolation(details map[string]string, field, reason string) {
+	if field == "" {
+		field = "request"
+	}
+	if existing, ok := details[field]; ok {
+		reason = existing + "; " + reason
+	}
+	details[field] = reason
+}
+
+// capturingResponseWriter passes a response through while keeping a copy of
+// the status code and body for validation
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *capturingResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *capturingResponseWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through; the copy of the body is kept until the
+// response is complete
+func (rw *capturingResponseWriter) Flush() {
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *capturingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YoloWingPixie/calendar-api/docs"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// setupSpecTest builds the full router with mock repositories and response
+// validation enabled
+func setupSpecTest(t *testing.T) (*mux.Router, *OpenAPIValidator) {
+	t.Helper()
+
+	config := &Config{
+		BootstrapAdminKey:         "test-admin-key-123",
+		APIKeyHeader:              "X-API-Key",
+		Environment:               "test",
+		IdempotencyKeyTTL:         time.Hour,
+		BatchMaxOperations:        10,
+		APIKeyRotationGracePeriod: time.Hour,
+		AuthMaxFailures:           10,
+		AuthLockoutDuration:       time.Minute,
+	}
+
+	eventRepo := NewMockEventRepository()
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	auditRepo := &MockAuditRepository{}
+
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config)
+	if err := authMiddleware.ReconcileBootstrapAdmin(t.Context()); err != nil {
+		t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+	}
+
+	validator, err := NewOpenAPIValidator(docs.OpenAPI, true)
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI document: %v", err)
+	}
+	openAPIHandler, err := NewOpenAPIHandler(docs.OpenAPI, docsAssets(t), "test")
+	if err != nil {
+		t.Fatalf("Failed to prepare OpenAPI handler: %v", err)
+	}
+
+	healthChecker := NewHealthChecker()
+	healthChecker.Register(HealthCheckDatabase, DatabasePingCheck(eventRepo.Ping, pingCacheTTL))
+
+	// httptest requests come from 192.0.2.1, standing in for the load balancer
+	trustedProxies, _ := ParseTrustedProxies("192.0.2.1")
+
+	r := NewRouter(Routes{
+		Auth:           authMiddleware,
+		Audit:          NewAuditMiddleware(auditRepo),
+		Idempotency:    NewIdempotencyMiddleware(NewMockIdempotencyStore(), config),
+		Validator:      validator,
+		MaxBodyBytes:   64 << 10,
+		TrustedProxies: trustedProxies,
+		Health:         NewHealthHandler(healthChecker),
+		OpenAPI:        openAPIHandler,
+		Events:         NewEventHandler(eventRepo),
+		Batch:          NewBatchHandler(eventRepo, config),
+		Users:          NewUserHandler(userRepo),
+		APIKeys:        NewAPIKeyHandler(apiKeyRepo, userRepo, config),
+		Lockouts:       NewAuthLockoutHandler(authMiddleware.failures),
+		AuditLog:       NewAuditHandler(auditRepo),
+		Metrics:        promhttp.Handler(),
+	})
+
+	return r, validator
+}
+
+// routeKey identifies a route by method and path template
+func routeKey(method, template string) string {
+	return method + " " + template
+}
+
+func TestOpenAPIDocumentCoversEveryRoute(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	r, validator := setupSpecTest(t)
+
+	// Every registered route must be described by the spec
+	routes := make(map[string]bool)
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		// Subrouter method matchers are copied onto their routes, so
+		// find the methods a route serves by matching sample requests
+		var pairs []string
+		names, _ := route.GetVarNames()
+		for _, name := range names {
+			pairs = append(pairs, name, "1")
+		}
+		url, err := route.URLPath(pairs...)
+		if err != nil {
+			return err
+		}
+
+		for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+			var match mux.RouteMatch
+			if !route.Match(httptest.NewRequest(method, url.String(), nil), &match) || match.MatchErr != nil {
+				continue
+			}
+			if _, _, operation := validator.Operation(template, method); operation == nil {
+				t.Errorf("%s %s is not described by the OpenAPI document", method, template)
+			}
+			routes[routeKey(method, template)] = false
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk router: %v", err)
+	}
+
+	adminKey := "test-admin-key-123"
+	var eventID, userID, userKey, keyID string
+
+	serve := func(method, path, body, apiKey string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		var req *http.Request
+		if body != "" {
+			req = httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		var match mux.RouteMatch
+		if r.Match(req, &match) && match.Route != nil {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				routes[routeKey(method, template)] = true
+			}
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder, v interface{}) {
+		t.Helper()
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+
+	eventBody := `{"title":"Planning","description":"Quarterly planning","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`
+	steps := []struct {
+		name   string
+		method string
+		path   func() string
+		body   string
+		key    func() string
+		header map[string]string
+		status int
+		after  func(w *httptest.ResponseRecorder)
+	}{
+		{name: "Liveness", method: "GET", path: func() string { return "/livez" }, status: http.StatusOK},
+		{name: "Readiness", method: "GET", path: func() string { return "/readyz" }, status: http.StatusOK},
+		{name: "Verbose health", method: "GET", path: func() string { return "/health?verbose=true" }, status: http.StatusOK},
+		{name: "Version", method: "GET", path: func() string { return "/version" }, status: http.StatusOK},
+		{name: "Spec as YAML", method: "GET", path: func() string { return "/openapi.yaml" }, status: http.StatusOK},
+		{name: "Spec as JSON", method: "GET", path: func() string { return "/openapi.json" }, status: http.StatusOK},
+		{name: "Docs page", method: "GET", path: func() string { return "/docs" }, status: http.StatusOK},
+		{name: "Docs stylesheet", method: "GET", path: func() string { return "/docs/assets/swagger-ui.css" }, status: http.StatusOK},
+		{name: "Docs script", method: "GET", path: func() string { return "/docs/assets/swagger-ui-bundle.js" }, status: http.StatusOK},
+		{name: "Unknown docs asset", method: "GET", path: func() string { return "/docs/assets/missing.js" }, status: http.StatusNotFound},
+		{name: "Metrics", method: "GET", path: func() string { return "/metrics" }, status: http.StatusOK},
+		{
+			name: "Rejected key", method: "GET", path: func() string { return "/api/events" },
+			key: func() string { return "wrong-key" }, header: map[string]string{"X-Forwarded-For": "198.51.100.7"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "Create event", method: "POST", path: func() string { return "/api/events" }, body: eventBody,
+			header: map[string]string{"Idempotency-Key": "spec-test"}, status: http.StatusCreated,
+			after: func(w *httptest.ResponseRecorder) {
+				var event Event
+				decode(w, &event)
+				eventID = event.ID
+			},
+		},
+		{name: "List events", method: "GET", path: func() string { return "/api/events" }, status: http.StatusOK},
+		{name: "Get event", method: "GET", path: func() string { return "/api/events/" + eventID }, status: http.StatusOK},
+		{name: "Get missing event", method: "GET", path: func() string { return "/api/events/missing" }, status: http.StatusNotFound},
+		{
+			name: "Update event", method: "PUT", path: func() string { return "/api/events/" + eventID },
+			body: strings.Replace(eventBody, "Planning", "Replanning", 1), status: http.StatusOK,
+		},
+		{name: "List revisions", method: "GET", path: func() string { return "/api/events/" + eventID + "/revisions" }, status: http.StatusOK},
+		{name: "Get revision", method: "GET", path: func() string { return "/api/events/" + eventID + "/revisions/1" }, status: http.StatusOK},
+		{name: "Diff revisions", method: "GET", path: func() string { return "/api/events/" + eventID + "/revisions/diff?from=1&to=2" }, status: http.StatusOK},
+		{name: "Revert event", method: "POST", path: func() string { return "/api/events/" + eventID + "/revisions/1:revert" }, status: http.StatusOK},
+		{
+			name: "Batch", method: "POST", path: func() string { return "/api/events:batch" },
+			body: `{"mode":"best_effort","operations":[{"op":"create","event":` + eventBody + `},{"op":"delete","id":"missing"}]}`, status: http.StatusOK,
+		},
+		{name: "Delete event", method: "DELETE", path: func() string { return "/api/events/" + eventID }, status: http.StatusNoContent},
+		{name: "List trash", method: "GET", path: func() string { return "/api/trash" }, status: http.StatusOK},
+		{name: "Restore event", method: "POST", path: func() string { return "/api/events/" + eventID + ":restore" }, status: http.StatusOK},
+		{
+			name: "Create user", method: "POST", path: func() string { return "/api/users" },
+			body: `{"username":"spec-user","role":"member"}`, status: http.StatusCreated,
+			after: func(w *httptest.ResponseRecorder) {
+				var user CreateUserResponse
+				decode(w, &user)
+				userID, userKey = user.ID, user.APIKey
+			},
+		},
+		{name: "List users", method: "GET", path: func() string { return "/api/users" }, status: http.StatusOK},
+		{name: "Get user", method: "GET", path: func() string { return "/api/users/" + userID }, status: http.StatusOK},
+		{name: "Update user", method: "PATCH", path: func() string { return "/api/users/" + userID }, body: `{"role":"service"}`, status: http.StatusOK},
+		{name: "Disable user", method: "POST", path: func() string { return "/api/users/" + userID + ":disable" }, status: http.StatusOK},
+		{name: "Enable user", method: "POST", path: func() string { return "/api/users/" + userID + ":enable" }, status: http.StatusOK},
+		{
+			name: "Member cannot list users", method: "GET", path: func() string { return "/api/users" },
+			key: func() string { return userKey }, status: http.StatusForbidden,
+		},
+		{name: "List keys", method: "GET", path: func() string { return "/api/users/" + userID + "/keys" }, status: http.StatusOK},
+		{
+			name: "Create key", method: "POST", path: func() string { return "/api/users/" + userID + "/keys" },
+			body: `{"name":"ci","scopes":["events:read"]}`, status: http.StatusCreated,
+			after: func(w *httptest.ResponseRecorder) {
+				var key CreateAPIKeyResponse
+				decode(w, &key)
+				keyID = key.ID
+			},
+		},
+		{
+			name: "Rotate key", method: "POST", path: func() string { return "/api/users/" + userID + "/keys/" + keyID + ":rotate" },
+			status: http.StatusCreated,
+			after: func(w *httptest.ResponseRecorder) {
+				var key RotateAPIKeyResponse
+				decode(w, &key)
+				keyID = key.ID
+			},
+		},
+		{name: "Revoke key", method: "POST", path: func() string { return "/api/users/" + userID + "/keys/" + keyID + ":revoke" }, status: http.StatusOK},
+		{name: "List lockouts", method: "GET", path: func() string { return "/api/auth/lockouts" }, status: http.StatusOK},
+		{name: "Clear lockout", method: "DELETE", path: func() string { return "/api/auth/lockouts/198.51.100.7" }, status: http.StatusNoContent},
+		{name: "Query audit log", method: "GET", path: func() string { return "/api/audit?resource=users&limit=10" }, status: http.StatusOK},
+		{name: "Delete user", method: "DELETE", path: func() string { return "/api/users/" + userID }, status: http.StatusNoContent},
+	}
+
+	for _, step := range steps {
+		key := adminKey
+		if step.key != nil {
+			key = step.key()
+		}
+
+		path := step.path()
+		w := serve(step.method, path, step.body, key, step.header)
+		if w.Code != step.status {
+			t.Fatalf("%s: expected status %d for %s %s, got %d: %s", step.name, step.status, step.method, path, w.Code, w.Body.String())
+		}
+		if step.after != nil {
+			step.after(w)
+		}
+	}
+
+	for _, entry := range logs.All() {
+		t.Errorf("%s: %v", entry.Message, entry.ContextMap())
+	}
+	for route, exercised := range routes {
+		if !exercised {
+			t.Errorf("%s is not exercised", route)
+		}
+	}
+}
+
+func TestOpenAPIValidatorRejectsInvalidRequests(t *testing.T) {
+	r, _ := setupSpecTest(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		field  string
+	}{
+		{name: "Missing required property", method: "POST", path: "/api/events", body: `{"start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`, field: "/title"},
+		{name: "Wrong property type", method: "POST", path: "/api/events", body: `{"title":1,"start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`, field: "/title"},
+		{name: "Invalid date-time", method: "PUT", path: "/api/events/1", body: `{"title":"Planning","start_time":"tomorrow","end_time":"2030-01-01T11:00:00Z"}`, field: "/start_time"},
+		{name: "Unknown enum value", method: "POST", path: "/api/users", body: `{"username":"spec-user","role":"owner"}`, field: "/role"},
+		{name: "Missing body", method: "POST", path: "/api/users", field: "body"},
+		{name: "Query parameter out of range", method: "GET", path: "/api/audit?limit=0", field: "limit"},
+		{name: "Query parameter of wrong type", method: "GET", path: "/api/events/1/revisions/diff?from=first&to=2", field: "from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if tt.body != "" {
+				req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+			} else {
+				req = httptest.NewRequest(tt.method, tt.path, nil)
+			}
+			req.Header.Set("X-API-Key", "test-admin-key-123")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.RequestID == "" {
+				t.Error("Expected request ID in error response")
+			}
+			if _, ok := response.Details[tt.field]; !ok {
+				t.Errorf("Expected violation for %q, got %v", tt.field, response.Details)
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidatorUnauthenticatedBeforeValidation(t *testing.T) {
+	r, _ := setupSpecTest(t)
+
+	req := httptest.NewRequest("POST", "/api/events", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 before request validation, got %d", w.Code)
+	}
+}
+
+func TestOpenAPIValidatorReportsResponseViolations(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	validator, err := NewOpenAPIValidator(docs.OpenAPI, true)
+	if err != nil {
+		t.Fatalf("Failed to load OpenAPI document: %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(validator.Middleware)
+	r.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, http.StatusOK, map[string]string{"version": "1.0.0"})
+	}).Methods("GET")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the response to be passed through, got status %d", w.Code)
+	}
+	if logs.FilterMessage("Response does not match the OpenAPI document").Len() != 1 {
+		t.Errorf("Expected the response violation to be logged, got %v", logs.All())
+	}
+}
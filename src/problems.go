@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details. Clients
+// that list it in Accept receive errors in this format; all others receive
+// ErrorResponse.
+const ProblemContentType = "application/problem+json"
+
+// problemTypeBase prefixes the lower-cased error code to form the problem
+// type URI, which resolves to the code's entry in docs/errors.md
+const problemTypeBase = "https://github.com/YoloWingPixie/calendar-api/blob/main/docs/errors.md#"
+
+// ErrorCode is a stable, machine-readable identifier of an error. Codes are
+// never renamed once released; docs/errors.md lists each one.
+type ErrorCode string
+
+// General error codes
+const (
+	CodeInternalError        ErrorCode = "INTERNAL_ERROR"
+	CodeRouteNotFound        ErrorCode = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeInvalidRequestBody   ErrorCode = "INVALID_REQUEST_BODY"
+	CodeRequestBodyTooLarge  ErrorCode = "REQUEST_BODY_TOO_LARGE"
+	CodeUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	CodeInvalidQueryParam    ErrorCode = "INVALID_QUERY_PARAMETER"
+	CodeRateLimited          ErrorCode = "RATE_LIMITED"
+)
+
+// Authentication and authorization error codes
+const (
+	CodeAuthenticationRequired ErrorCode = "AUTHENTICATION_REQUIRED"
+	CodeAPIKeyInvalid          ErrorCode = "API_KEY_INVALID"
+	CodeAPIKeyRevoked          ErrorCode = "API_KEY_REVOKED"
+	CodeAPIKeyExpired          ErrorCode = "API_KEY_EXPIRED"
+	CodeBearerTokenInvalid     ErrorCode = "BEARER_TOKEN_INVALID"
+	CodeUserUnknown            ErrorCode = "USER_UNKNOWN"
+	CodeUserDisabled           ErrorCode = "USER_DISABLED"
+	CodeAuthLockedOut          ErrorCode = "AUTH_LOCKED_OUT"
+	CodeAdminRequired          ErrorCode = "ADMIN_REQUIRED"
+	CodeResourceForbidden      ErrorCode = "RESOURCE_FORBIDDEN"
+	CodeInsufficientScope      ErrorCode = "INSUFFICIENT_SCOPE"
+)
+
+// Event error codes
+const (
+	CodeEventNotFound     ErrorCode = "EVENT_NOT_FOUND"
+	CodeEventNotInTrash   ErrorCode = "EVENT_NOT_IN_TRASH"
+	CodeTimeFormatInvalid ErrorCode = "TIME_FORMAT_INVALID"
+	CodeRevisionInvalid   ErrorCode = "REVISION_INVALID"
+	CodeRevisionNotFound  ErrorCode = "REVISION_NOT_FOUND"
+	CodeBatchTooLarge     ErrorCode = "BATCH_TOO_LARGE"
+)
+
+// Idempotency error codes
+const (
+	CodeIdempotencyKeyInvalid    ErrorCode = "IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyKeyReused     ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress ErrorCode = "IDEMPOTENCY_KEY_IN_PROGRESS"
+)
+
+// User, API key and lockout management error codes
+const (
+	CodeUserNotFound              ErrorCode = "USER_NOT_FOUND"
+	CodeUsernameTaken             ErrorCode = "USERNAME_TAKEN"
+	CodeSelfModificationForbidden ErrorCode = "SELF_MODIFICATION_FORBIDDEN"
+	CodeAPIKeyNotFound            ErrorCode = "API_KEY_NOT_FOUND"
+	CodeAPIKeyAlreadyRevoked      ErrorCode = "API_KEY_ALREADY_REVOKED"
+	CodeAPIKeyNotActive           ErrorCode = "API_KEY_NOT_ACTIVE"
+	CodeExpiresAtInvalid          ErrorCode = "EXPIRES_AT_INVALID"
+	CodeLockoutNotFound           ErrorCode = "LOCKOUT_NOT_FOUND"
+)
+
+// errorCodeInfo is the HTTP status and problem title of an error code
+type errorCodeInfo struct {
+	status int
+	title  string
+}
+
+// errorCodes is the error code catalogue
+var errorCodes = map[ErrorCode]errorCodeInfo{
+	CodeInternalError:        {http.StatusInternalServerError, "Internal server error"},
+	CodeRouteNotFound:        {http.StatusNotFound, "Route not found"},
+	CodeMethodNotAllowed:     {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeInvalidRequestBody:   {http.StatusBadRequest, "Invalid request body"},
+	CodeRequestBodyTooLarge:  {http.StatusRequestEntityTooLarge, "Request body too large"},
+	CodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	CodeValidationFailed:     {http.StatusBadRequest, "Validation failed"},
+	CodeInvalidQueryParam:    {http.StatusBadRequest, "Invalid query parameter"},
+	CodeRateLimited:          {http.StatusTooManyRequests, "Rate limit exceeded"},
+
+	CodeAuthenticationRequired: {http.StatusUnauthorized, "Authentication required"},
+	CodeAPIKeyInvalid:          {http.StatusUnauthorized, "Invalid API key"},
+	CodeAPIKeyRevoked:          {http.StatusUnauthorized, "API key revoked"},
+	CodeAPIKeyExpired:          {http.StatusUnauthorized, "API key expired"},
+	CodeBearerTokenInvalid:     {http.StatusUnauthorized, "Invalid bearer token"},
+	CodeUserUnknown:            {http.StatusUnauthorized, "Unknown user"},
+	CodeUserDisabled:           {http.StatusForbidden, "User disabled"},
+	CodeAuthLockedOut:          {http.StatusTooManyRequests, "Too many failed authentication attempts"},
+	CodeAdminRequired:          {http.StatusForbidden, "Administrator access required"},
+	CodeResourceForbidden:      {http.StatusForbidden, "Resource belongs to another user"},
+	CodeInsufficientScope:      {http.StatusForbidden, "Insufficient API key scope"},
+
+	CodeEventNotFound:     {http.StatusNotFound, "Event not found"},
+	CodeEventNotInTrash:   {http.StatusNotFound, "Event not in trash"},
+	CodeTimeFormatInvalid: {http.StatusBadRequest, "Invalid time format"},
+	CodeRevisionInvalid:   {http.StatusBadRequest, "Invalid revision number"},
+	CodeRevisionNotFound:  {http.StatusNotFound, "Revision not found"},
+	CodeBatchTooLarge:     {http.StatusRequestEntityTooLarge, "Batch too large"},
+
+	CodeIdempotencyKeyInvalid:    {http.StatusBadRequest, "Invalid idempotency key"},
+	CodeIdempotencyKeyReused:     {http.StatusUnprocessableEntity, "Idempotency key reused"},
+	CodeIdempotencyKeyInProgress: {http.StatusConflict, "Idempotent request in progress"},
+
+	CodeUserNotFound:              {http.StatusNotFound, "User not found"},
+	CodeUsernameTaken:             {http.StatusConflict, "Username taken"},
+	CodeSelfModificationForbidden: {http.StatusConflict, "Cannot modify own account"},
+	CodeAPIKeyNotFound:            {http.StatusNotFound, "API key not found"},
+	CodeAPIKeyAlreadyRevoked:      {http.StatusConflict, "API key already revoked"},
+	CodeAPIKeyNotActive:           {http.StatusConflict, "API key not active"},
+	CodeExpiresAtInvalid:          {http.StatusBadRequest, "Invalid expiry"},
+	CodeLockoutNotFound:           {http.StatusNotFound, "Lockout not found"},
+}
+
+// Status returns the HTTP status of the error code
+func (c ErrorCode) Status() int {
+	if info, ok := errorCodes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title returns the short, fixed summary of the error code
+func (c ErrorCode) Title() string {
+	if info, ok := errorCodes[c]; ok {
+		return info.title
+	}
+	return http.StatusText(c.Status())
+}
+
+// Type returns the problem type URI of the error code
+func (c ErrorCode) Type() string {
+	return problemTypeBase + strings.ToLower(string(c))
+}
+
+// Problem represents an RFC 7807 problem details response
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      ErrorCode         `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// acceptsProblem reports whether the client lists application/problem+json
+// in its Accept header with a non-zero quality
+func acceptsProblem(r *http.Request) bool {
+	for _, mr := range parseAccept(r) {
+		if mr.mediaType == ProblemContentType && mr.q != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// errorResponse writes the error identified by code with a human-readable
+// detail. err is logged, at error level for server errors, and is never
+// sent to the client; pass nil when the caller has already logged it.
+func errorResponse(w http.ResponseWriter, r *http.Request, code ErrorCode, detail string, err error) {
+	errorDetailsResponse(w, r, code, detail, nil, err)
+}
+
+// errorDetailsResponse is errorResponse with per-field details, such as
+// validation failures or a missing scope
+func errorDetailsResponse(w http.ResponseWriter, r *http.Request, code ErrorCode, detail string, details map[string]string, err error) {
+	status := code.Status()
+	requestID := w.Header().Get(RequestIDHeader)
+
+	if err != nil {
+		level := zapcore.DebugLevel
+		if status >= http.StatusInternalServerError {
+			level = zapcore.ErrorLevel
+		}
+		LoggerFromContext(r.Context()).Log(level, detail, zap.String("code", string(code)), zap.Error(err))
+	}
+
+	if !acceptsProblem(r) {
+		label := http.StatusText(status)
+		if code == CodeValidationFailed {
+			label = "Validation Failed" // Kept from before error codes existed
+		}
+		jsonResponse(w, status, ErrorResponse{
+			Error:     label,
+			Message:   detail,
+			Code:      code,
+			Details:   details,
+			RequestID: requestID,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:      code.Type(),
+		Title:     code.Title(),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID,
+		Details:   details,
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorResponseNegotiation(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	r, _ := setupSpecTest(t)
+
+	tests := []struct {
+		name    string
+		accept  string
+		problem bool
+	}{
+		{name: "No Accept header", accept: "", problem: false},
+		{name: "JSON", accept: "application/json", problem: false},
+		{name: "Problem details", accept: "application/problem+json", problem: true},
+		{name: "Problem details among others", accept: "application/json;q=0.5, application/problem+json", problem: true},
+		{name: "Problem details refused", accept: "application/problem+json;q=0, application/json", problem: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/events/missing", nil)
+			req.Header.Set("X-API-Key", "test-admin-key-123")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("Expected status 404, got %d: %s", w.Code, w.Body.String())
+			}
+
+			if !tt.problem {
+				if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Expected Content-Type application/json, got %q", ct)
+				}
+				var response ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.Error != "Not Found" || response.Code != CodeEventNotFound {
+					t.Errorf("Unexpected error response: %+v", response)
+				}
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("Expected Content-Type %s, got %q", ProblemContentType, ct)
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+			if problem.Type != CodeEventNotFound.Type() {
+				t.Errorf("Expected type %q, got %q", CodeEventNotFound.Type(), problem.Type)
+			}
+			if problem.Title != "Event not found" || problem.Status != http.StatusNotFound || problem.Code != CodeEventNotFound {
+				t.Errorf("Unexpected problem: %+v", problem)
+			}
+			if problem.Instance != "/api/events/missing" {
+				t.Errorf("Expected instance /api/events/missing, got %q", problem.Instance)
+			}
+			if problem.RequestID == "" || problem.RequestID != w.Header().Get(RequestIDHeader) {
+				t.Errorf("Expected request ID %q, got %q", w.Header().Get(RequestIDHeader), problem.RequestID)
+			}
+		})
+	}
+
+	// Responses are validated against the spec, which describes both formats
+	if logs.Len() != 0 {
+		t.Errorf("Expected no response violations, got %v", logs.All())
+	}
+}
+
+func TestUnmatchedRoutesReturnErrorCodes(t *testing.T) {
+	r, _ := setupSpecTest(t)
+
+	tests := []struct {
+		method string
+		path   string
+		code   ErrorCode
+	}{
+		{method: "GET", path: "/nowhere", code: CodeRouteNotFound},
+		{method: "POST", path: "/livez", code: CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", ProblemContentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.code.Status() {
+				t.Fatalf("Expected status %d, got %d", tt.code.Status(), w.Code)
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("Failed to unmarshal problem: %v", err)
+			}
+			if problem.Code != tt.code || problem.RequestID == "" {
+				t.Errorf("Unexpected problem: %+v", problem)
+			}
+		})
+	}
+}
+
+func TestErrorDetailsInProblem(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	req.Header.Set("Accept", ProblemContentType)
+	w := httptest.NewRecorder()
+
+	scopeErrorResponse(w, req, ScopeEventsRead)
+
+	var problem Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to unmarshal problem: %v", err)
+	}
+	if problem.Code != CodeInsufficientScope || problem.Details["missing_scope"] != string(ScopeEventsRead) {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+}
+
+func TestErrorResponseLogsCause(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defaultLogger := logger
+	logger = zap.New(core)
+	defer func() { logger = defaultLogger }()
+
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	errorResponse(httptest.NewRecorder(), req, CodeInternalError, "Failed to list events", os.ErrDeadlineExceeded)
+	errorResponse(httptest.NewRecorder(), req, CodeInvalidRequestBody, "Invalid JSON", os.ErrInvalid)
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 log entries, got %d", len(entries))
+	}
+	if entries[0].Level != zapcore.ErrorLevel || entries[1].Level != zapcore.DebugLevel {
+		t.Errorf("Expected error then debug level, got %s then %s", entries[0].Level, entries[1].Level)
+	}
+
+	w := httptest.NewRecorder()
+	errorResponse(w, req, CodeInternalError, "Failed to list events", os.ErrDeadlineExceeded)
+	if strings.Contains(w.Body.String(), os.ErrDeadlineExceeded.Error()) {
+		t.Error("Expected the cause to be withheld from the client")
+	}
+}
+
+func TestErrorCodeCatalogue(t *testing.T) {
+	_, validator := setupSpecTest(t)
+
+	schema, ok := validator.doc.Components.Schemas["ErrorCode"]
+	if !ok {
+		t.Fatal("Expected an ErrorCode schema in the OpenAPI document")
+	}
+	documented := make(map[ErrorCode]bool)
+	for _, value := range schema.Value.Enum {
+		code := ErrorCode(value.(string))
+		documented[code] = true
+		if _, ok := errorCodes[code]; !ok {
+			t.Errorf("OpenAPI document lists %s, which is not in the catalogue", code)
+		}
+	}
+
+	guide, err := os.ReadFile("../docs/errors.md")
+	if err != nil {
+		t.Fatalf("Failed to read error guide: %v", err)
+	}
+
+	for code, info := range errorCodes {
+		if !documented[code] {
+			t.Errorf("%s is missing from the OpenAPI ErrorCode enum", code)
+		}
+		if !strings.Contains(string(guide), "### "+string(code)+"\n") {
+			t.Errorf("%s is missing from docs/errors.md", code)
+		}
+		if info.status < 400 || info.title == "" {
+			t.Errorf("%s has an incomplete catalogue entry: %+v", code, info)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Rate limit store backends
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+const (
+	// rateLimitAnonymous labels limits applied to unauthenticated requests
+	rateLimitAnonymous = "anonymous"
+
+	// RateLimitPruneInterval is how often idle rate limit state is dropped
+	RateLimitPruneInterval = time.Minute
+)
+
+// RateLimit allows Requests requests per Window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<requests>/<window>", e.g. "600/1m"
+func ParseRateLimit(value string) (RateLimit, error) {
+	requests, window, found := strings.Cut(value, "/")
+	if !found {
+		return RateLimit{}, fmt.Errorf("rate limit %q must be written as <requests>/<window>", value)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must allow a positive number of requests", value)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must have a positive window", value)
+	}
+
+	return RateLimit{Requests: n, Window: d}, nil
+}
+
+// String formats the limit as a RateLimit-Policy header value
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(math.Ceil(l.Window.Seconds())))
+}
+
+// RateLimitResult describes the outcome of taking a request from a limit
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // Time until the full quota is available again
+	RetryAfter time.Duration // Time until the next request is allowed, if denied
+}
+
+// RateLimitStore tracks request counts per client
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+	Prune(ctx context.Context, now time.Time) error
+}
+
+// MemoryRateLimitStore implements per-process token buckets
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	capacity float64
+	rate     float64 // Tokens added per second
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take removes a token from the client's bucket, refilling it for the time
+// elapsed since the last request
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Window.Seconds()
+
+	bucket, ok := s.buckets[key]
+	if !ok || bucket.capacity != capacity || bucket.rate != rate {
+		bucket = &tokenBucket{tokens: capacity, updated: now, capacity: capacity, rate: rate}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*rate)
+		bucket.updated = now
+	}
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((capacity - bucket.tokens) / rate)
+	return result, nil
+}
+
+// Prune drops buckets that have refilled completely, since they are
+// indistinguishable from new ones
+func (s *MemoryRateLimitStore) Prune(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*bucket.rate >= bucket.capacity {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// PostgresRateLimitStore implements fixed-window counters shared by every
+// instance of the service
+type PostgresRateLimitStore struct {
+	db *DB
+}
+
+// NewPostgresRateLimitStore creates a store backed by the rate_limits table
+func NewPostgresRateLimitStore(db *DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+// Take increments the client's counter for the current window
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	ctx, span := startSpan(ctx, "PostgresRateLimitStore.Take")
+	defer span.End()
+
+	windowStart := now.Truncate(limit.Window)
+	windowEnd := windowStart.Add(limit.Window)
+
+	query := `
+		INSERT INTO rate_limits (key, window_start, expires_at, count)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limits.count + 1
+		RETURNING count
+	`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, key, windowStart, windowEnd).Scan(&count); err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to update rate limit counter: %w", err)
+	}
+
+	reset := windowEnd.Sub(now)
+	result := RateLimitResult{
+		Allowed:   count <= limit.Requests,
+		Remaining: max(limit.Requests-count, 0),
+		Reset:     reset,
+	}
+	if !result.Allowed {
+		result.RetryAfter = reset
+	}
+	return result, nil
+}
+
+// Prune deletes counters of windows that have ended
+func (s *PostgresRateLimitStore) Prune(ctx context.Context, now time.Time) error {
+	ctx, span := startSpan(ctx, "PostgresRateLimitStore.Prune")
+	defer span.End()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE expires_at <= $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired rate limit counters: %w", err)
+	}
+	return nil
+}
+
+// RateLimitMiddleware enforces per-client request limits
+type RateLimitMiddleware struct {
+	store     RateLimitStore
+	roles     map[string]RateLimit
+	anonymous RateLimit
+}
+
+// NewRateLimitMiddleware creates a rate limiter from configuration. Limits
+// are validated by Config.validate.
+func NewRateLimitMiddleware(store RateLimitStore, config *Config) *RateLimitMiddleware {
+	parse := func(value string) RateLimit {
+		limit, _ := ParseRateLimit(value)
+		return limit
+	}
+
+	return &RateLimitMiddleware{
+		store: store,
+		roles: map[string]RateLimit{
+			RoleAdmin:   parse(config.RateLimitAdmin),
+			RoleMember:  parse(config.RateLimitMember),
+			RoleService: parse(config.RateLimitService),
+		},
+		anonymous: parse(config.RateLimitAnonymous),
+	}
+}
+
+// LimitByUser limits authenticated requests per API key, or per user for
+// bearer tokens, using the limit of the user's role. It must run after
+// authentication.
+func (m *RateLimitMiddleware) LimitByUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			m.limit(w, r, next, "ip:"+clientIP(r), rateLimitAnonymous, m.anonymous)
+			return
+		}
+
+		key := "user:" + user.ID
+		if apiKey, ok := GetAPIKey(r.Context()); ok {
+			key = "key:" + apiKey.ID
+		}
+
+		limit, ok := m.roles[user.Role]
+		if !ok {
+			limit = m.roles[RoleMember]
+		}
+
+		m.limit(w, r, next, key, user.Role, limit)
+	})
+}
+
+// LimitByIP limits unauthenticated requests per client IP
+func (m *RateLimitMiddleware) LimitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.limit(w, r, next, "ip:"+clientIP(r), rateLimitAnonymous, m.anonymous)
+	})
+}
+
+func (m *RateLimitMiddleware) limit(w http.ResponseWriter, r *http.Request, next http.Handler, key, class string, limit RateLimit) {
+	result, err := m.store.Take(r.Context(), key, limit, time.Now())
+	if err != nil {
+		// Fail open so that a store outage does not take the API down
+		LoggerFromContext(r.Context()).Warn("Rate limit check failed, allowing request", zap.Error(err))
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("RateLimit-Policy", limit.String())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+	if !result.Allowed {
+		rateLimitedRequestsTotal.WithLabelValues(class).Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+		errorResponse(w, r, CodeRateLimited, "Rate limit exceeded, retry later", nil)
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
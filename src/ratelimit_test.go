@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("600/1m")
+	if err != nil || limit.Requests != 600 || limit.Window != time.Minute {
+		t.Errorf("Unexpected result %+v, %v", limit, err)
+	}
+	if limit.String() != "600;w=60" {
+		t.Errorf("Unexpected policy %q", limit.String())
+	}
+
+	for _, value := range []string{"", "600", "0/1m", "abc/1m", "10/0s", "10/soon"} {
+		if _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Requests: 2, Window: time.Minute}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if result, _ := store.Take(context.Background(), "client", limit, now); !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	result, _ := store.Take(context.Background(), "client", limit, now)
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 30*time.Second {
+		t.Errorf("Expected request to be denied for 30s, got %+v", result)
+	}
+
+	if result, _ := store.Take(context.Background(), "other", limit, now); !result.Allowed {
+		t.Error("Expected other clients to have their own bucket")
+	}
+
+	// One token is refilled every 30 seconds
+	if result, _ := store.Take(context.Background(), "client", limit, now.Add(30*time.Second)); !result.Allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+
+	store.Prune(context.Background(), now.Add(time.Hour))
+	if len(store.buckets) != 0 {
+		t.Errorf("Expected refilled buckets to be pruned, got %d", len(store.buckets))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := &Config{
+		RateLimitAdmin:     "5/1m",
+		RateLimitMember:    "2/1m",
+		RateLimitService:   "5/1m",
+		RateLimitAnonymous: "1/1m",
+	}
+	limiter := NewRateLimitMiddleware(NewMemoryRateLimitStore(), config)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	byUser := limiter.LimitByUser(ok)
+	// httptest requests come from 192.0.2.1, standing in for the load balancer
+	proxies, _ := ParseTrustedProxies("192.0.2.1")
+	byIP := ClientIPMiddleware(proxies)(limiter.LimitByIP(ok))
+
+	member := &User{ID: "user-1", Role: RoleMember}
+	serveAs := func(key *APIKey) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/events", nil)
+		ctx := WithUser(req.Context(), member)
+		ctx = WithAPIKey(ctx, key)
+		w := httptest.NewRecorder()
+		byUser.ServeHTTP(w, req.WithContext(ctx))
+		return w
+	}
+
+	t.Run("Limits per API key", func(t *testing.T) {
+		first := &APIKey{ID: "key-1"}
+		for i := 0; i < 2; i++ {
+			if w := serveAs(first); w.Code != http.StatusOK {
+				t.Fatalf("Expected request %d to succeed, got %d", i+1, w.Code)
+			}
+		}
+
+		w := serveAs(first)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429, got %d", w.Code)
+		}
+		if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("Unexpected rate limit headers: %v", w.Header())
+		}
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON error response, got %q", w.Header().Get("Content-Type"))
+		}
+
+		if w := serveAs(&APIKey{ID: "key-2"}); w.Code != http.StatusOK {
+			t.Errorf("Expected a different key to have its own limit, got %d", w.Code)
+		}
+	})
+
+	t.Run("Limits anonymous requests per IP", func(t *testing.T) {
+		serve := func(forwardedFor string) int {
+			req := httptest.NewRequest("GET", "/version", nil)
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			w := httptest.NewRecorder()
+			byIP.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		if code := serve("203.0.113.7"); code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", code)
+		}
+		// A spoofed leading entry does not change the client identity
+		if code := serve("198.51.100.1, 203.0.113.7"); code != http.StatusTooManyRequests {
+			t.Errorf("Expected status 429, got %d", code)
+		}
+		if code := serve("203.0.113.8"); code != http.StatusOK {
+			t.Errorf("Expected a different IP to have its own limit, got %d", code)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -10,81 +11,122 @@ import (
 
 // EventRepositoryInterface defines the interface for event repository
 type EventRepositoryInterface interface {
-	Create(event *Event) error
-	Get(id string) (*Event, error)
-	Update(id string, event *Event) error
-	Delete(id string) error
-	List() ([]Event, error)
-	Ping() error
+	Create(ctx context.Context, event *Event) error
+	Get(ctx context.Context, id string) (*Event, error)
+	Update(ctx context.Context, id string, event *Event) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]Event, error)
+	Each(ctx context.Context, fn func(*Event) error) error
+	ListTrash(ctx context.Context) ([]Event, error)
+	Restore(ctx context.Context, id string) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	RecordRevision(ctx context.Context, revision *EventRevision) error
+	ListRevisions(ctx context.Context, eventID string) ([]EventRevision, error)
+	GetRevision(ctx context.Context, eventID string, revision int) (*EventRevision, error)
+	Ping(ctx context.Context) error
+	WithTransaction(ctx context.Context, fn func(tx EventRepositoryInterface) error) error
+}
+
+// eventColumns lists the columns selected for an Event, in scan order
+const eventColumns = "id, title, description, start_time, end_time, created_at, updated_at, deleted_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is the subset of database methods shared by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // EventRepository handles database operations for events
 type EventRepository struct {
 	db *DB
+	q  querier
 }
 
 // NewEventRepository creates a new event repository
 func NewEventRepository(db *DB) *EventRepository {
-	return &EventRepository{db: db}
+	return &EventRepository{db: db, q: db}
 }
 
 // List retrieves all events from the database
-func (r *EventRepository) List() ([]Event, error) {
+func (r *EventRepository) List(ctx context.Context) ([]Event, error) {
+	ctx, span := startSpan(ctx, "EventRepository.List")
+	defer span.End()
+
 	query := `
-		SELECT id, title, description, start_time, end_time, created_at, updated_at
+		SELECT ` + eventColumns + `
 		FROM events
+		WHERE deleted_at IS NULL
 		ORDER BY start_time ASC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.q.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
 	defer rows.Close()
 
-	var events []Event
+	return scanEvents(rows)
+}
+
+// Each calls fn for every event that List would return, in the same order,
+// reading rows from the database cursor one at a time. The event passed to
+// fn is reused for the next row, so fn must not retain it. Each stops at the
+// first error returned by fn and returns it. The cursor holds a pooled
+// connection until Each returns, so callers that wait on a client inside fn
+// should bound ctx.
+func (r *EventRepository) Each(ctx context.Context, fn func(*Event) error) error {
+	ctx, span := startSpan(ctx, "EventRepository.Each")
+	defer span.End()
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE deleted_at IS NULL
+		ORDER BY start_time ASC
+	`
+
+	rows, err := r.q.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var event Event
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(
-			&event.ID,
-			&event.Title,
-			&event.Description,
-			&event.StartTime,
-			&event.EndTime,
-			&event.CreatedAt,
-			&event.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan event: %w", err)
+		if err := scanEvent(rows, &event); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+		if err := fn(&event); err != nil {
+			return err
 		}
-		events = append(events, event)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	return events, nil
+	return nil
 }
 
 // Get retrieves a single event by ID
-func (r *EventRepository) Get(id string) (*Event, error) {
+func (r *EventRepository) Get(ctx context.Context, id string) (*Event, error) {
+	ctx, span := startSpan(ctx, "EventRepository.Get")
+	defer span.End()
+
 	query := `
-		SELECT id, title, description, start_time, end_time, created_at, updated_at
+		SELECT ` + eventColumns + `
 		FROM events
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var event Event
-	err := r.db.QueryRow(query, id).Scan(
-		&event.ID,
-		&event.Title,
-		&event.Description,
-		&event.StartTime,
-		&event.EndTime,
-		&event.CreatedAt,
-		&event.UpdatedAt,
-	)
+	err := scanEvent(r.q.QueryRowContext(ctx, query, id), &event)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -97,7 +139,10 @@ func (r *EventRepository) Get(id string) (*Event, error) {
 }
 
 // Create inserts a new event into the database
-func (r *EventRepository) Create(event *Event) error {
+func (r *EventRepository) Create(ctx context.Context, event *Event) error {
+	ctx, span := startSpan(ctx, "EventRepository.Create")
+	defer span.End()
+
 	event.ID = uuid.New().String()
 	event.CreatedAt = time.Now().UTC()
 	event.UpdatedAt = event.CreatedAt
@@ -107,7 +152,7 @@ func (r *EventRepository) Create(event *Event) error {
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err := r.db.Exec(
+	_, err := r.q.ExecContext(ctx,
 		query,
 		event.ID,
 		event.Title,
@@ -126,16 +171,19 @@ func (r *EventRepository) Create(event *Event) error {
 }
 
 // Update modifies an existing event in the database
-func (r *EventRepository) Update(id string, event *Event) error {
+func (r *EventRepository) Update(ctx context.Context, id string, event *Event) error {
+	ctx, span := startSpan(ctx, "EventRepository.Update")
+	defer span.End()
+
 	event.UpdatedAt = time.Now().UTC()
 
 	query := `
 		UPDATE events
 		SET title = $2, description = $3, start_time = $4, end_time = $5, updated_at = $6
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	result, err := r.db.Exec(
+	result, err := r.q.ExecContext(ctx,
 		query,
 		id,
 		event.Title,
@@ -161,11 +209,14 @@ func (r *EventRepository) Update(id string, event *Event) error {
 	return nil
 }
 
-// Delete removes an event from the database
-func (r *EventRepository) Delete(id string) error {
-	query := `DELETE FROM events WHERE id = $1`
+// Delete moves an event to the trash by marking it as deleted
+func (r *EventRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "EventRepository.Delete")
+	defer span.End()
+
+	query := `UPDATE events SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
 
-	result, err := r.db.Exec(query, id)
+	result, err := r.q.ExecContext(ctx, query, id, time.Now().UTC())
 	if err != nil {
 		return fmt.Errorf("failed to delete event: %w", err)
 	}
@@ -182,7 +233,128 @@ func (r *EventRepository) Delete(id string) error {
 	return nil
 }
 
+// ListTrash retrieves all deleted events, most recently deleted first
+func (r *EventRepository) ListTrash(ctx context.Context) ([]Event, error) {
+	ctx, span := startSpan(ctx, "EventRepository.ListTrash")
+	defer span.End()
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := r.q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// Restore moves a deleted event out of the trash
+func (r *EventRepository) Restore(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "EventRepository.Restore")
+	defer span.End()
+
+	query := `
+		UPDATE events
+		SET deleted_at = NULL, updated_at = $2
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.q.ExecContext(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to restore event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes events that were deleted before the given time
+func (r *EventRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, "EventRepository.PurgeDeleted")
+	defer span.End()
+
+	query := `DELETE FROM events WHERE deleted_at IS NOT NULL AND deleted_at <= $1`
+
+	result, err := r.q.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted events: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// WithTransaction runs fn against a repository bound to a single database
+// transaction. The transaction is committed if fn returns nil and rolled
+// back otherwise. Calls on a repository that is already transactional reuse
+// the existing transaction.
+func (r *EventRepository) WithTransaction(ctx context.Context, fn func(tx EventRepositoryInterface) error) error {
+	ctx, span := startSpan(ctx, "EventRepository.WithTransaction")
+	defer span.End()
+
+	if _, ok := r.q.(*sql.Tx); ok {
+		return fn(r)
+	}
+
+	return r.db.InTransaction(ctx, func(tx *sql.Tx) error {
+		return fn(&EventRepository{db: r.db, q: tx})
+	})
+}
+
 // Ping checks database connectivity
-func (r *EventRepository) Ping() error {
-	return r.db.Ping()
+func (r *EventRepository) Ping(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "EventRepository.Ping")
+	defer span.End()
+
+	return r.db.PingContext(ctx)
+}
+
+// scanEvent scans a row selected with eventColumns into event
+func scanEvent(row rowScanner, event *Event) error {
+	return row.Scan(
+		&event.ID,
+		&event.Title,
+		&event.Description,
+		&event.StartTime,
+		&event.EndTime,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&event.DeletedAt,
+	)
+}
+
+// scanEvents scans all rows selected with eventColumns
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	var events []Event
+	for rows.Next() {
+		var event Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return events, nil
 }
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RecordRevision stores an immutable snapshot of an event. The revision
+// number is assigned as the next number for the event.
+func (r *EventRepository) RecordRevision(ctx context.Context, revision *EventRevision) error {
+	ctx, span := startSpan(ctx, "EventRepository.RecordRevision")
+	defer span.End()
+
+	revision.ID = uuid.New().String()
+	revision.CreatedAt = time.Now().UTC()
+
+	snapshot, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision snapshot: %w", err)
+	}
+
+	query := `
+		INSERT INTO event_revisions (id, event_id, revision, action, snapshot, actor_id, actor_username, created_at)
+		SELECT $1, $2, COALESCE(MAX(revision), 0) + 1, $3, $4, $5, $6, $7
+		FROM event_revisions
+		WHERE event_id = $2
+		RETURNING revision
+	`
+
+	err = r.q.QueryRowContext(ctx,
+		query,
+		revision.ID,
+		revision.EventID,
+		revision.Action,
+		snapshot,
+		revision.ActorID,
+		revision.ActorUsername,
+		revision.CreatedAt,
+	).Scan(&revision.Revision)
+
+	if err != nil {
+		return fmt.Errorf("failed to record event revision: %w", err)
+	}
+
+	return nil
+}
+
+// ListRevisions retrieves all revisions of an event, oldest first
+func (r *EventRepository) ListRevisions(ctx context.Context, eventID string) ([]EventRevision, error) {
+	ctx, span := startSpan(ctx, "EventRepository.ListRevisions")
+	defer span.End()
+
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM event_revisions
+		WHERE event_id = $1
+		ORDER BY revision ASC
+	`
+
+	rows, err := r.q.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []EventRevision
+	for rows.Next() {
+		var revision EventRevision
+		if err := scanRevision(rows, &revision); err != nil {
+			return nil, fmt.Errorf("failed to scan event revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetRevision retrieves a single revision of an event
+func (r *EventRepository) GetRevision(ctx context.Context, eventID string, number int) (*EventRevision, error) {
+	ctx, span := startSpan(ctx, "EventRepository.GetRevision")
+	defer span.End()
+
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM event_revisions
+		WHERE event_id = $1 AND revision = $2
+	`
+
+	var revision EventRevision
+	err := scanRevision(r.q.QueryRowContext(ctx, query, eventID, number), &revision)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event revision: %w", err)
+	}
+
+	return &revision, nil
+}
+
+// revisionColumns lists the columns selected for an EventRevision, in scan order
+const revisionColumns = "id, event_id, revision, action, snapshot, actor_id, actor_username, created_at"
+
+// scanRevision scans a row selected with revisionColumns into revision
+func scanRevision(row rowScanner, revision *EventRevision) error {
+	var snapshot []byte
+	err := row.Scan(
+		&revision.ID,
+		&revision.EventID,
+		&revision.Revision,
+		&revision.Action,
+		&snapshot,
+		&revision.ActorID,
+		&revision.ActorUsername,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(snapshot, &revision.Snapshot)
+}
+
+// newRevision builds a revision of event attributed to the user in ctx
+func newRevision(ctx context.Context, action string, event *Event) *EventRevision {
+	revision := &EventRevision{
+		EventID:  event.ID,
+		Action:   action,
+		Snapshot: *event,
+	}
+
+	if user, ok := GetUser(ctx); ok {
+		revision.ActorID = user.ID
+		revision.ActorUsername = user.Username
+	}
+
+	return revision
+}
+
+// ListRevisions handles GET /api/events/{id}/revisions
+func (h *EventHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("list", "event_revisions", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	revisions, err := h.repo.ListRevisions(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve revisions", err)
+		return
+	}
+
+	if len(revisions) == 0 {
+		errorResponse(w, r, CodeEventNotFound, "Event not found", nil)
+		return
+	}
+
+	response := ListRevisionsResponse{
+		Revisions: revisions,
+		Count:     len(revisions),
+	}
+
+	h.jsonResponse(w, http.StatusOK, response)
+}
+
+// GetRevision handles GET /api/events/{id}/revisions/{revision}
+func (h *EventHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("get", "event_revisions", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		errorResponse(w, r, CodeRevisionInvalid, "Invalid revision number", err)
+		return
+	}
+
+	revision, err := h.repo.GetRevision(r.Context(), id, number)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve revision", err)
+		return
+	}
+
+	if revision == nil {
+		errorResponse(w, r, CodeRevisionNotFound, "Revision not found", nil)
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, revision)
+}
+
+// DiffRevisions handles GET /api/events/{id}/revisions/diff?from=N&to=M
+func (h *EventHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("diff", "event_revisions", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		errorResponse(w, r, CodeInvalidQueryParam, "Query parameter 'from' must be a revision number", err)
+		return
+	}
+
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		errorResponse(w, r, CodeInvalidQueryParam, "Query parameter 'to' must be a revision number", err)
+		return
+	}
+
+	fromRevision, err := h.repo.GetRevision(r.Context(), id, from)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve revision", err)
+		return
+	}
+
+	toRevision, err := h.repo.GetRevision(r.Context(), id, to)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve revision", err)
+		return
+	}
+
+	if fromRevision == nil || toRevision == nil {
+		errorResponse(w, r, CodeRevisionNotFound, "Revision not found", nil)
+		return
+	}
+
+	response := RevisionDiffResponse{
+		EventID: id,
+		From:    from,
+		To:      to,
+		Changes: diffEvents(&fromRevision.Snapshot, &toRevision.Snapshot),
+	}
+
+	h.jsonResponse(w, http.StatusOK, response)
+}
+
+// RevertEvent handles POST /api/events/{id}/revisions/{revision}:revert
+func (h *EventHandler) RevertEvent(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("revert", "events", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		errorResponse(w, r, CodeRevisionInvalid, "Invalid revision number", err)
+		return
+	}
+
+	var reverted *Event
+	var code ErrorCode
+	message := ""
+	err = h.repo.WithTransaction(r.Context(), func(tx EventRepositoryInterface) error {
+		revision, txErr := tx.GetRevision(r.Context(), id, number)
+		if txErr != nil {
+			return txErr
+		}
+		if revision == nil {
+			code, message = CodeRevisionNotFound, "Revision not found"
+			return sql.ErrNoRows
+		}
+
+		existing, txErr := tx.Get(r.Context(), id)
+		if txErr != nil {
+			return txErr
+		}
+		if existing == nil {
+			code, message = CodeEventNotFound, "Event not found"
+			return sql.ErrNoRows
+		}
+
+		event := &Event{
+			ID:          id,
+			Title:       revision.Snapshot.Title,
+			Description: revision.Snapshot.Description,
+			StartTime:   revision.Snapshot.StartTime,
+			EndTime:     revision.Snapshot.EndTime,
+			CreatedAt:   existing.CreatedAt,
+		}
+		if txErr = tx.Update(r.Context(), id, event); txErr != nil {
+			return txErr
+		}
+
+		reverted, txErr = tx.Get(r.Context(), id)
+		if txErr != nil {
+			return txErr
+		}
+
+		return tx.RecordRevision(r.Context(), newRevision(r.Context(), RevisionActionRevert, reverted))
+	})
+
+	if err != nil {
+		if message != "" {
+			errorResponse(w, r, code, message, nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to revert event", err)
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, reverted)
+}
+
+// diffEvents lists the user-visible fields that differ between two snapshots
+func diffEvents(from, to *Event) []RevisionFieldChange {
+	changes := []RevisionFieldChange{}
+
+	if from.Title != to.Title {
+		changes = append(changes, RevisionFieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+	if !equalStringPtr(from.Description, to.Description) {
+		changes = append(changes, RevisionFieldChange{Field: "description", From: from.Description, To: to.Description})
+	}
+	if !from.StartTime.Equal(to.StartTime) {
+		changes = append(changes, RevisionFieldChange{Field: "start_time", From: from.StartTime, To: to.StartTime})
+	}
+	if !from.EndTime.Equal(to.EndTime) {
+		changes = append(changes, RevisionFieldChange{Field: "end_time", From: from.EndTime, To: to.EndTime})
+	}
+	if (from.DeletedAt == nil) != (to.DeletedAt == nil) {
+		changes = append(changes, RevisionFieldChange{Field: "deleted_at", From: from.DeletedAt, To: to.DeletedAt})
+	}
+
+	return changes
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEventRevisions(t *testing.T) {
+	repo := NewMockEventRepository()
+	handler := NewEventHandler(repo)
+	user := &User{ID: "test-user", Username: "testuser"}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/events", handler.CreateEvent).Methods("POST")
+	router.HandleFunc("/api/events/{id}/revisions", handler.ListRevisions).Methods("GET")
+	router.HandleFunc("/api/events/{id}/revisions/diff", handler.DiffRevisions).Methods("GET")
+	router.HandleFunc("/api/events/{id}/revisions/{revision:[0-9]+}", handler.GetRevision).Methods("GET")
+	router.HandleFunc("/api/events/{id}/revisions/{revision:[0-9]+}:revert", handler.RevertEvent).Methods("POST")
+	router.HandleFunc("/api/events/{id}", handler.UpdateEvent).Methods("PUT")
+
+	serve := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			json.NewEncoder(&body).Encode(payload)
+		}
+		req := httptest.NewRequest(method, path, &body)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	w := serve("POST", "/api/events", CreateEventRequest{
+		Title:     "Original",
+		StartTime: start.Format(time.RFC3339),
+		EndTime:   start.Add(time.Hour).Format(time.RFC3339),
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create test event: %d %s", w.Code, w.Body.String())
+	}
+	var created Event
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = serve("PUT", "/api/events/"+created.ID, UpdateEventRequest{
+		Title:     "Moved",
+		StartTime: start.Add(2 * time.Hour).Format(time.RFC3339),
+		EndTime:   start.Add(3 * time.Hour).Format(time.RFC3339),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to update test event: %d %s", w.Code, w.Body.String())
+	}
+
+	t.Run("List revisions", func(t *testing.T) {
+		w := serve("GET", "/api/events/"+created.ID+"/revisions", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var response ListRevisionsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Count != 2 {
+			t.Fatalf("Expected 2 revisions, got %d", response.Count)
+		}
+		if response.Revisions[0].Action != RevisionActionCreate || response.Revisions[1].Action != RevisionActionUpdate {
+			t.Errorf("Unexpected revision actions: %+v", response.Revisions)
+		}
+		if response.Revisions[1].ActorID != user.ID {
+			t.Errorf("Expected actor %s, got %s", user.ID, response.Revisions[1].ActorID)
+		}
+	})
+
+	t.Run("Get missing revision", func(t *testing.T) {
+		if w := serve("GET", "/api/events/"+created.ID+"/revisions/9", nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("Diff revisions", func(t *testing.T) {
+		w := serve("GET", "/api/events/"+created.ID+"/revisions/diff?from=1&to=2", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var response RevisionDiffResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+
+		fields := map[string]bool{}
+		for _, change := range response.Changes {
+			fields[change.Field] = true
+		}
+		if len(fields) != 3 || !fields["title"] || !fields["start_time"] || !fields["end_time"] {
+			t.Errorf("Expected title, start_time and end_time changes, got %+v", response.Changes)
+		}
+	})
+
+	t.Run("Revert to first revision", func(t *testing.T) {
+		w := serve("POST", "/api/events/"+created.ID+"/revisions/1:revert", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+
+		var reverted Event
+		json.Unmarshal(w.Body.Bytes(), &reverted)
+		if reverted.Title != "Original" || !reverted.StartTime.Equal(start) {
+			t.Errorf("Expected event to be reverted, got %+v", reverted)
+		}
+
+		revisions, _ := repo.ListRevisions(context.Background(), created.ID)
+		if len(revisions) != 3 || revisions[2].Action != RevisionActionRevert {
+			t.Errorf("Expected a revert revision to be recorded, got %+v", revisions)
+		}
+	})
+}
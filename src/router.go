@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+)
+
+// Routes holds the handlers and middleware served by the API
+type Routes struct {
+	Auth        *AuthMiddleware
+	Audit       *AuditMiddleware
+	Idempotency *IdempotencyMiddleware
+	RateLimiter *RateLimitMiddleware // nil when rate limiting is disabled
+	Validator   *OpenAPIValidator
+
+	MaxBodyBytes   int64        // request bodies are unlimited when zero
+	TrustedProxies []*net.IPNet // X-Forwarded-For is ignored when empty
+
+	Health   *HealthHandler
+	OpenAPI  *OpenAPIHandler
+	Events   *EventHandler
+	Batch    *BatchHandler
+	Users    *UserHandler
+	APIKeys  *APIKeyHandler
+	Lockouts *AuthLockoutHandler
+	AuditLog *AuditHandler
+	Metrics  http.Handler
+}
+
+// NewRouter registers every route of the API
+func NewRouter(rt Routes) *mux.Router {
+	r := mux.NewRouter()
+
+	// Liveness and readiness probes, exempt from rate limiting
+	r.HandleFunc("/livez", rt.Health.Livez).Methods("GET")
+	r.HandleFunc("/readyz", rt.Health.Readyz).Methods("GET")
+
+	// Public routes (no authentication required)
+	public := r.NewRoute().Subrouter()
+	public.HandleFunc("/health", rt.Health.Health).Methods("GET")
+	public.HandleFunc("/version", rt.Events.VersionCheck).Methods("GET")
+	public.HandleFunc("/openapi.yaml", rt.OpenAPI.YAML).Methods("GET")
+	public.HandleFunc("/openapi.json", rt.OpenAPI.JSON).Methods("GET")
+	public.HandleFunc("/docs", rt.OpenAPI.Docs).Methods("GET")
+	public.HandleFunc("/docs/assets/{file}", rt.OpenAPI.Asset).Methods("GET")
+
+	// Protected API routes (require authentication). Mutating calls are
+	// audited, including those rejected during authentication.
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(rt.Audit.Record)
+	api.Use(rt.Auth.RequireAuthentication)
+
+	if rt.RateLimiter != nil {
+		public.Use(rt.RateLimiter.LimitByIP)
+		api.Use(rt.RateLimiter.LimitByUser)
+	}
+
+	// Requests are checked against the OpenAPI document once they are
+	// authenticated and within their rate limit
+	public.Use(rt.Validator.Middleware)
+	api.Use(rt.Validator.Middleware)
+
+	// Event routes, split by the API key scope they require
+	eventsRead := api.Methods("GET").Subrouter()
+	eventsRead.Use(rt.Auth.RequireScope(ScopeEventsRead))
+	eventsRead.HandleFunc("/events", rt.Events.ListEvents)
+	eventsRead.HandleFunc("/events/{id}/revisions", rt.Events.ListRevisions)
+	eventsRead.HandleFunc("/events/{id}/revisions/diff", rt.Events.DiffRevisions)
+	eventsRead.HandleFunc("/events/{id}/revisions/{revision:[0-9]+}", rt.Events.GetRevision)
+	eventsRead.HandleFunc("/events/{id}", rt.Events.GetEvent)
+	eventsRead.HandleFunc("/trash", rt.Events.ListTrash)
+
+	eventsWrite := api.Methods("POST", "PUT", "DELETE").Subrouter()
+	eventsWrite.Use(rt.Auth.RequireScope(ScopeEventsWrite))
+	eventsWrite.Handle("/events", rt.Idempotency.WithIdempotency(http.HandlerFunc(rt.Events.CreateEvent))).Methods("POST")
+	eventsWrite.Handle("/events:batch", rt.Idempotency.WithIdempotency(http.HandlerFunc(rt.Batch.BatchEvents))).Methods("POST")
+	eventsWrite.HandleFunc("/events/{id}:restore", rt.Events.RestoreEvent).Methods("POST")
+	eventsWrite.HandleFunc("/events/{id}/revisions/{revision:[0-9]+}:revert", rt.Events.RevertEvent).Methods("POST")
+	eventsWrite.HandleFunc("/events/{id}", rt.Events.UpdateEvent).Methods("PUT")
+	eventsWrite.HandleFunc("/events/{id}", rt.Events.DeleteEvent).Methods("DELETE")
+
+	// API key management routes, available to the key owner and administrators
+	keys := api.PathPrefix("/users/{id}/keys").Subrouter()
+	keys.Use(rt.Auth.RequireScope(ScopeKeysManage))
+	keys.Use(rt.Auth.RequireSelfOrAdmin)
+	keys.HandleFunc("", rt.APIKeys.ListKeys).Methods("GET")
+	keys.HandleFunc("", rt.APIKeys.CreateKey).Methods("POST")
+	keys.HandleFunc("/{key_id}:revoke", rt.APIKeys.RevokeKey).Methods("POST")
+	keys.HandleFunc("/{key_id}:rotate", rt.APIKeys.RotateKey).Methods("POST")
+
+	// Admin-only user management routes
+	users := api.PathPrefix("/users").Subrouter()
+	users.Use(rt.Auth.RequireScope(ScopeUsersAdmin))
+	users.Use(rt.Auth.RequireAdmin)
+	users.HandleFunc("", rt.Users.ListUsers).Methods("GET")
+	users.HandleFunc("", rt.Users.CreateUser).Methods("POST")
+	users.HandleFunc("/{id}:disable", rt.Users.DisableUser).Methods("POST")
+	users.HandleFunc("/{id}:enable", rt.Users.EnableUser).Methods("POST")
+	users.HandleFunc("/{id}", rt.Users.GetUser).Methods("GET")
+	users.HandleFunc("/{id}", rt.Users.UpdateUser).Methods("PATCH")
+	users.HandleFunc("/{id}", rt.Users.DeleteUser).Methods("DELETE")
+
+	// Admin-only authentication lockout routes
+	lockouts := api.PathPrefix("/auth/lockouts").Subrouter()
+	lockouts.Use(rt.Auth.RequireScope(ScopeUsersAdmin))
+	lockouts.Use(rt.Auth.RequireAdmin)
+	lockouts.HandleFunc("", rt.Lockouts.ListLockouts).Methods("GET")
+	lockouts.HandleFunc("/{ip}", rt.Lockouts.ClearLockout).Methods("DELETE")
+
+	// Admin-only audit log route
+	audit := api.PathPrefix("/audit").Subrouter()
+	audit.Use(rt.Auth.RequireScope(ScopeUsersAdmin))
+	audit.Use(rt.Auth.RequireAdmin)
+	audit.HandleFunc("", rt.AuditLog.ListEntries).Methods("GET")
+
+	// Middleware. Tracing runs first so that request logs carry the trace ID.
+	r.Use(otelmux.Middleware(tracingServiceName))
+	r.Use(RequestIDMiddleware)
+	r.Use(ClientIPMiddleware(rt.TrustedProxies))
+	r.Use(LoggingMiddleware)
+	r.Use(MetricsMiddleware)
+	r.Use(CORSMiddleware)
+	if rt.MaxBodyBytes > 0 {
+		r.Use(BodyLimitMiddleware(rt.MaxBodyBytes))
+	}
+
+	// Unmatched requests bypass router middleware, so wrap them in the same
+	// request ID, client IP, logging and metrics middleware explicitly
+	unmatched := func(h http.HandlerFunc) http.Handler {
+		return RequestIDMiddleware(ClientIPMiddleware(rt.TrustedProxies)(LoggingMiddleware(MetricsMiddleware(h))))
+	}
+	r.NotFoundHandler = unmatched(func(w http.ResponseWriter, r *http.Request) {
+		errorResponse(w, r, CodeRouteNotFound, "No route matches "+r.URL.Path, nil)
+	})
+	r.MethodNotAllowedHandler = unmatched(func(w http.ResponseWriter, r *http.Request) {
+		errorResponse(w, r, CodeMethodNotAllowed, r.Method+" is not supported for "+r.URL.Path, nil)
+	})
+
+	// Prometheus metrics endpoint, exempt from rate limiting
+	r.Path("/metrics").Handler(rt.Metrics).Methods("GET")
+
+	return r
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// API key scopes
+const (
+	ScopeAll         = "*"
+	ScopeEventsRead  = "events:read"
+	ScopeEventsWrite = "events:write"
+	ScopeKeysManage  = "keys:manage"
+	ScopeUsersAdmin  = "users:admin"
+)
+
+// hasScope reports whether granted includes the required scope, either
+// directly or through the wildcard scope
+func hasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == ScopeAll || scope == required {
+			return true
+		}
+	}
+	return false
+}
+
+// missingScope returns the first of the requested scopes that the request's
+// credentials do not grant, or "" if all are granted. API keys grant their
+// stored scopes and bearer tokens the scopes in their scope or scp claim.
+// Bearer tokens without either claim are limited only by the user's role.
+func missingScope(r *http.Request, requested []string) string {
+	var granted []string
+	if key, ok := GetAPIKey(r.Context()); ok {
+		granted = key.Scopes
+	} else if scopes, ok := GetTokenScopes(r.Context()); ok {
+		granted = scopes
+	} else {
+		return ""
+	}
+
+	for _, scope := range requested {
+		if !hasScope(granted, scope) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// RequireScope returns middleware that rejects requests whose API key or
+// bearer token does not grant the given scope. It must run after
+// RequireAuthentication.
+func (a *AuthMiddleware) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if missing := missingScope(r, []string{scope}); missing != "" {
+				LoggerFromContext(r.Context()).Info("Credentials are missing scope", zap.String("scope", missing))
+				scopeErrorResponse(w, r, missing)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// scopeErrorResponse writes a 403 response naming the missing scope
+func scopeErrorResponse(w http.ResponseWriter, r *http.Request, scope string) {
+	errorDetailsResponse(w, r, CodeInsufficientScope, "Credentials are missing a required scope",
+		map[string]string{"missing_scope": scope}, nil)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScopedAPIKeys(t *testing.T) {
+	router, _ := setupUserTest(t)
+	const adminKey = "test-admin-key-123"
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/users", adminKey, `{"username":"dashboard"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create user: %d %s", w.Code, w.Body.String())
+	}
+	var user CreateUserResponse
+	json.Unmarshal(w.Body.Bytes(), &user)
+	keysPath := "/api/users/" + user.ID + "/keys"
+
+	w = serve("POST", keysPath, user.APIKey, `{"name":"read-only","scopes":["events:read"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create scoped key: %d %s", w.Code, w.Body.String())
+	}
+	var readOnly CreateAPIKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &readOnly)
+	if len(readOnly.Scopes) != 1 || readOnly.Scopes[0] != ScopeEventsRead {
+		t.Fatalf("Expected events:read scope, got %v", readOnly.Scopes)
+	}
+
+	t.Run("Read-only key can read", func(t *testing.T) {
+		if w := serve("GET", "/api/events", readOnly.Key, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Read-only key cannot write", func(t *testing.T) {
+		w := serve("POST", "/api/events", readOnly.Key, "{}")
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("Expected status 403, got %d", w.Code)
+		}
+
+		var response ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Details["missing_scope"] != ScopeEventsWrite {
+			t.Errorf("Expected missing scope %s, got %+v", ScopeEventsWrite, response.Details)
+		}
+	})
+
+	t.Run("Read-only key cannot manage keys", func(t *testing.T) {
+		w := serve("GET", keysPath, readOnly.Key, "")
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("Keys cannot grant scopes they lack", func(t *testing.T) {
+		w := serve("POST", keysPath, user.APIKey, `{"name":"narrow","scopes":["events:read","keys:manage"]}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create key: %d %s", w.Code, w.Body.String())
+		}
+		var narrow CreateAPIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &narrow)
+
+		w = serve("POST", keysPath, narrow.Key, `{"name":"wider","scopes":["events:write"]}`)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+
+		w = serve("POST", keysPath, narrow.Key, `{"name":"inherited"}`)
+		var inherited CreateAPIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &inherited)
+		if w.Code != http.StatusCreated || len(inherited.Scopes) != 2 {
+			t.Errorf("Expected key to inherit the caller's scopes, got %d %v", w.Code, inherited.Scopes)
+		}
+
+		// Rotating the unrestricted default key would hand out a new "*" key
+		w = serve("GET", keysPath, user.APIKey, "")
+		var list ListAPIKeysResponse
+		json.Unmarshal(w.Body.Bytes(), &list)
+		rotated := false
+		for _, key := range list.Keys {
+			if key.Name != "default" {
+				continue
+			}
+			rotated = true
+			w = serve("POST", keysPath+"/"+key.ID+":rotate", narrow.Key, "")
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected rotating a wider key to be forbidden, got %d %s", w.Code, w.Body.String())
+			}
+		}
+		if !rotated {
+			t.Fatal("Expected the user to have a default key")
+		}
+		w = serve("POST", keysPath+"/"+inherited.ID+":rotate", narrow.Key, "")
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected rotating a key within the caller's scopes to succeed, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Unknown scopes are rejected", func(t *testing.T) {
+		w := serve("POST", keysPath, user.APIKey, `{"name":"bad","scopes":["calendars:admin"]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+}
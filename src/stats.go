@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// User account states used as metric labels
+const (
+	userStatusActive   = "active"
+	userStatusDisabled = "disabled"
+)
+
+// BusinessCounts is a snapshot of the totals exported as business gauges
+type BusinessCounts struct {
+	ActiveEvents   int64
+	DeletedEvents  int64
+	UpcomingEvents int64
+	Users          map[UserCountKey]int64
+}
+
+// UserCountKey groups users by role and account status
+type UserCountKey struct {
+	Role   string
+	Status string
+}
+
+// StatsRepositoryInterface defines the interface for aggregate queries
+type StatsRepositoryInterface interface {
+	BusinessCounts(ctx context.Context, now time.Time) (*BusinessCounts, error)
+}
+
+// StatsRepository handles aggregate queries across tables
+type StatsRepository struct {
+	db *DB
+}
+
+// NewStatsRepository creates a new stats repository
+func NewStatsRepository(db *DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// BusinessCounts counts events and users. Events starting after now are
+// counted as upcoming.
+func (r *StatsRepository) BusinessCounts(ctx context.Context, now time.Time) (*BusinessCounts, error) {
+	ctx, span := startSpan(ctx, "StatsRepository.BusinessCounts")
+	defer span.End()
+
+	counts := &BusinessCounts{Users: make(map[UserCountKey]int64)}
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE deleted_at IS NULL),
+			COUNT(*) FILTER (WHERE deleted_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE deleted_at IS NULL AND start_time > $1)
+		FROM events
+	`
+
+	err := r.db.QueryRowContext(ctx, query, now).Scan(&counts.ActiveEvents, &counts.DeletedEvents, &counts.UpcomingEvents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT role, disabled, COUNT(*) FROM users GROUP BY role, disabled`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			role     string
+			disabled bool
+			count    int64
+		)
+		if err := rows.Scan(&role, &disabled, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan user count: %w", err)
+		}
+
+		key := UserCountKey{Role: role, Status: userStatusActive}
+		if disabled {
+			key.Status = userStatusDisabled
+		}
+		counts.Users[key] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// businessMetricsTimeout bounds the count queries run during a scrape. It is
+// kept below the Prometheus default scrape timeout of 10 seconds.
+const businessMetricsTimeout = 5 * time.Second
+
+// BusinessMetricsCollector exports current event and user totals queried
+// from the database. Counts are cached so that frequent scrapes do not add
+// database load, and the last successful counts are kept if a query fails.
+type BusinessMetricsCollector struct {
+	repo    StatsRepositoryInterface
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu         sync.Mutex
+	counts     *BusinessCounts
+	fetchedAt  time.Time
+	refreshing bool
+
+	activeEvents   *prometheus.Desc
+	deletedEvents  *prometheus.Desc
+	upcomingEvents *prometheus.Desc
+	users          *prometheus.Desc
+}
+
+// NewBusinessMetricsCollector creates a collector that refreshes its counts
+// at most once per ttl
+func NewBusinessMetricsCollector(repo StatsRepositoryInterface, ttl time.Duration) *BusinessMetricsCollector {
+	return &BusinessMetricsCollector{
+		repo:    repo,
+		ttl:     ttl,
+		timeout: businessMetricsTimeout,
+		activeEvents: prometheus.NewDesc(
+			"active_events", "Number of events that are not in the trash", nil, nil,
+		),
+		deletedEvents: prometheus.NewDesc(
+			"deleted_events", "Number of events in the trash", nil, nil,
+		),
+		upcomingEvents: prometheus.NewDesc(
+			"upcoming_events", "Number of active events that have not started yet", nil, nil,
+		),
+		users: prometheus.NewDesc(
+			"users", "Number of users by role and account status", []string{"role", "status"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *BusinessMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activeEvents
+	ch <- c.deletedEvents
+	ch <- c.upcomingEvents
+	ch <- c.users
+}
+
+// Collect implements prometheus.Collector
+func (c *BusinessMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := c.snapshot(time.Now())
+	if counts == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.activeEvents, prometheus.GaugeValue, float64(counts.ActiveEvents))
+	ch <- prometheus.MustNewConstMetric(c.deletedEvents, prometheus.GaugeValue, float64(counts.DeletedEvents))
+	ch <- prometheus.MustNewConstMetric(c.upcomingEvents, prometheus.GaugeValue, float64(counts.UpcomingEvents))
+	for key, count := range counts.Users {
+		ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(count), key.Role, key.Status)
+	}
+}
+
+// snapshot returns the cached counts, refreshing them once they are older
+// than the ttl. The query runs without holding the lock, and scrapes that
+// arrive while a refresh is in progress get the cached counts.
+func (c *BusinessMetricsCollector) snapshot(now time.Time) *BusinessCounts {
+	c.mu.Lock()
+	if c.refreshing || (c.counts != nil && now.Sub(c.fetchedAt) < c.ttl) {
+		defer c.mu.Unlock()
+		return c.counts
+	}
+	c.refreshing = true
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	counts, err := c.repo.BusinessCounts(ctx, now.UTC())
+	RecordDBOperation("count", "stats", time.Since(start))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+
+	if err != nil {
+		logger.Warn("Failed to refresh business metrics", zap.Error(err))
+		return c.counts
+	}
+
+	c.counts = counts
+	c.fetchedAt = now
+	return c.counts
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// MockStatsRepository implements StatsRepositoryInterface for testing
+type MockStatsRepository struct {
+	counts *BusinessCounts
+	err    error
+	calls  int
+
+	// started is signalled when a query begins, and block holds the query
+	// until it is closed or the context ends
+	started chan struct{}
+	block   chan struct{}
+}
+
+func (m *MockStatsRepository) BusinessCounts(ctx context.Context, now time.Time) (*BusinessCounts, error) {
+	m.calls++
+	if m.started != nil {
+		m.started <- struct{}{}
+	}
+	if m.block != nil {
+		select {
+		case <-m.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	counts := *m.counts
+	return &counts, nil
+}
+
+func TestBusinessMetricsCollector(t *testing.T) {
+	repo := &MockStatsRepository{
+		counts: &BusinessCounts{
+			ActiveEvents:   5,
+			DeletedEvents:  2,
+			UpcomingEvents: 3,
+			Users: map[UserCountKey]int64{
+				{Role: RoleAdmin, Status: userStatusActive}:    1,
+				{Role: RoleMember, Status: userStatusDisabled}: 4,
+			},
+		},
+	}
+	collector := NewBusinessMetricsCollector(repo, time.Hour)
+
+	expected := `
+# HELP active_events Number of events that are not in the trash
+# TYPE active_events gauge
+active_events 5
+# HELP deleted_events Number of events in the trash
+# TYPE deleted_events gauge
+deleted_events 2
+# HELP upcoming_events Number of active events that have not started yet
+# TYPE upcoming_events gauge
+upcoming_events 3
+# HELP users Number of users by role and account status
+# TYPE users gauge
+users{role="admin",status="active"} 1
+users{role="member",status="disabled"} 4
+`
+
+	t.Run("Export counts", func(t *testing.T) {
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Cache counts between scrapes", func(t *testing.T) {
+		repo.counts.ActiveEvents = 6
+		testutil.CollectAndCount(collector)
+		if repo.calls != 1 {
+			t.Errorf("Expected counts to be queried once, got %d", repo.calls)
+		}
+	})
+
+	t.Run("Keep last counts when refresh fails", func(t *testing.T) {
+		collector.ttl = 0
+		repo.err = errors.New("connection refused")
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+			t.Error(err)
+		}
+		if repo.calls != 2 {
+			t.Errorf("Expected a refresh attempt, got %d calls", repo.calls)
+		}
+	})
+
+	t.Run("Refresh after TTL", func(t *testing.T) {
+		repo.err = nil
+		refreshed := `
+# HELP active_events Number of events that are not in the trash
+# TYPE active_events gauge
+active_events 6
+`
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(refreshed), "active_events"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("No metrics before the first successful query", func(t *testing.T) {
+		failing := NewBusinessMetricsCollector(&MockStatsRepository{err: errors.New("down")}, time.Hour)
+		if n := testutil.CollectAndCount(failing); n != 0 {
+			t.Errorf("Expected no metrics, got %d", n)
+		}
+	})
+}
+
+func TestBusinessMetricsSlowQuery(t *testing.T) {
+	t.Run("Scrapes do not wait for a running refresh", func(t *testing.T) {
+		repo := &MockStatsRepository{
+			counts:  &BusinessCounts{ActiveEvents: 1},
+			started: make(chan struct{}),
+			block:   make(chan struct{}),
+		}
+		collector := NewBusinessMetricsCollector(repo, 0)
+
+		first := make(chan *BusinessCounts, 1)
+		go func() { first <- collector.snapshot(time.Now()) }()
+		<-repo.started
+
+		second := make(chan *BusinessCounts, 1)
+		go func() { second <- collector.snapshot(time.Now()) }()
+		select {
+		case counts := <-second:
+			if counts != nil {
+				t.Errorf("Expected no counts before the first refresh completes, got %+v", counts)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected a concurrent scrape not to wait for the running refresh")
+		}
+
+		close(repo.block)
+		if counts := <-first; counts == nil || counts.ActiveEvents != 1 {
+			t.Errorf("Expected the refresh to complete, got %+v", counts)
+		}
+	})
+
+	t.Run("Queries are bounded by the timeout", func(t *testing.T) {
+		repo := &MockStatsRepository{counts: &BusinessCounts{}, block: make(chan struct{})}
+		collector := NewBusinessMetricsCollector(repo, 0)
+		collector.timeout = 10 * time.Millisecond
+
+		if counts := collector.snapshot(time.Now()); counts != nil {
+			t.Errorf("Expected no counts after a timed out query, got %+v", counts)
+		}
+		collector.snapshot(time.Now())
+		if repo.calls != 2 {
+			t.Errorf("Expected the next scrape to retry, got %d calls", repo.calls)
+		}
+	})
+}
+
+func TestMetricsCacheTTLZeroFromSecrets(t *testing.T) {
+	t.Setenv("DOPPLER_SECRETS_JSON", `{"TF_VAR_metrics_cache_ttl":"0s"}`)
+	t.Setenv("METRICS_CACHE_TTL", "1m")
+
+	c := &Config{}
+	if err := c.loadFromDopplerSecrets(); err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	c.loadFromEnv()
+
+	if c.MetricsCacheTTL != 0 {
+		t.Errorf("Expected an explicit cache TTL of 0 to be kept, got %v", c.MetricsCacheTTL)
+	}
+}
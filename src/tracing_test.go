@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// failingQuerier fails every query without touching a database
+type failingQuerier struct{}
+
+func (failingQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func (failingQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func (failingQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	eventHandler := NewEventHandler(&EventRepository{q: failingQuerier{}})
+
+	r := mux.NewRouter()
+	r.Use(otelmux.Middleware(tracingServiceName))
+	r.HandleFunc("/api/events", eventHandler.ListEvents).Methods("GET")
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentSpanID = "00f067aa0ba902b7"
+	)
+
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	t.Run("Request span continues the incoming trace", func(t *testing.T) {
+		span, ok := spans["/api/events"]
+		if !ok {
+			t.Fatalf("Expected a span named after the route template, got %v", spans)
+		}
+		if span.SpanKind() != trace.SpanKindServer {
+			t.Errorf("Expected server span, got %s", span.SpanKind())
+		}
+		if got := span.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("Expected trace ID %s, got %s", traceID, got)
+		}
+		if got := span.Parent().SpanID().String(); got != parentSpanID {
+			t.Errorf("Expected parent span ID %s, got %s", parentSpanID, got)
+		}
+	})
+
+	t.Run("Repository span is a child of the request span", func(t *testing.T) {
+		span, ok := spans["EventRepository.List"]
+		if !ok {
+			t.Fatalf("Expected a repository span, got %v", spans)
+		}
+		if got, want := span.Parent().SpanID(), spans["/api/events"].SpanContext().SpanID(); got != want {
+			t.Errorf("Expected parent span ID %s, got %s", want, got)
+		}
+	})
+}
+
+func TestTraceFields(t *testing.T) {
+	if fields := traceFields(context.Background()); len(fields) != 0 {
+		t.Errorf("Expected no fields without a span, got %d", len(fields))
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	fields := traceFields(ctx)
+	if len(fields) != 2 || fields[0].String != traceID.String() || fields[1].String != spanID.String() {
+		t.Errorf("Expected trace_id and span_id fields, got %+v", fields)
+	}
+}
+
+func TestInitTracingRejectsUnknownExporter(t *testing.T) {
+	if _, err := InitTracing(context.Background(), &Config{TracingExporter: "jaeger"}); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+}
+
+func TestTracingSampleRatioZeroFromSecrets(t *testing.T) {
+	t.Setenv("DOPPLER_SECRETS_JSON", `{"TF_VAR_tracing_sample_ratio":"0"}`)
+	t.Setenv("TRACING_SAMPLE_RATIO", "0.5")
+
+	c := &Config{}
+	if err := c.loadFromDopplerSecrets(); err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	c.loadFromEnv()
+
+	if c.TracingSampleRatio != 0 {
+		t.Errorf("Expected an explicit sample ratio of 0 to be kept, got %v", c.TracingSampleRatio)
+	}
+}
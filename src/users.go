@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiKeyPrefix marks API keys generated by this service
+const apiKeyPrefix = "cal_"
+
+// generateAPIKey creates a new random API key
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// UserHandler handles HTTP requests for user management
+type UserHandler struct {
+	repo      UserRepositoryInterface
+	validator *RequestValidator
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(repo UserRepositoryInterface) *UserHandler {
+	return &UserHandler{
+		repo:      repo,
+		validator: NewRequestValidator(),
+	}
+}
+
+// CreateUser handles POST /api/users
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("create", "users", time.Since(start))
+	}()
+
+	var req CreateUserRequest
+	if !h.validator.Decode(w, r, &req) {
+		return
+	}
+
+	existing, err := h.repo.GetByUsername(r.Context(), req.Username)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to check for existing user", err)
+		return
+	}
+	if existing != nil {
+		errorResponse(w, r, CodeUsernameTaken, "Username already exists", nil)
+		return
+	}
+
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to generate API key", err)
+		return
+	}
+
+	role := req.Role
+	if role == "" {
+		role = RoleMember
+	}
+
+	user := &User{
+		Username: req.Username,
+		Role:     role,
+	}
+	key := &APIKey{
+		Name:   "default",
+		Key:    apiKey,
+		Scopes: []string{ScopeAll},
+	}
+
+	if err := h.repo.Create(r.Context(), user, key); err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to create user", err)
+		return
+	}
+
+	AddAuditTarget(r.Context(), "id", user.ID)
+	AddAuditTarget(r.Context(), "key_id", key.ID)
+	jsonResponse(w, http.StatusCreated, CreateUserResponse{
+		User:   *user,
+		APIKey: apiKey,
+	})
+}
+
+// ListUsers handles GET /api/users
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("list", "users", time.Since(start))
+	}()
+
+	users, err := h.repo.List(r.Context())
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve users", err)
+		return
+	}
+
+	if users == nil {
+		users = []User{}
+	}
+
+	jsonResponse(w, http.StatusOK, ListUsersResponse{
+		Users: users,
+		Count: len(users),
+	})
+}
+
+// GetUser handles GET /api/users/{id}
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("get", "users", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve user", err)
+		return
+	}
+
+	if user == nil {
+		errorResponse(w, r, CodeUserNotFound, "User not found", nil)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, user)
+}
+
+// UpdateUser handles PATCH /api/users/{id}
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("update", "users", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req UpdateUserRequest
+	if !h.validator.Decode(w, r, &req) {
+		return
+	}
+
+	if current, ok := GetUser(r.Context()); ok && current.ID == id && req.Role != current.Role {
+		errorResponse(w, r, CodeSelfModificationForbidden, "You cannot change the role of your own account", nil)
+		return
+	}
+
+	if err := h.repo.SetRole(r.Context(), id, req.Role); err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeUserNotFound, "User not found", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to update user", err)
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve updated user", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, user)
+}
+
+// DisableUser handles POST /api/users/{id}:disable
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+// EnableUser handles POST /api/users/{id}:enable
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *UserHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("update", "users", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if current, ok := GetUser(r.Context()); ok && current.ID == id {
+		errorResponse(w, r, CodeSelfModificationForbidden, "You cannot change the status of your own account", nil)
+		return
+	}
+
+	if err := h.repo.SetDisabled(r.Context(), id, disabled); err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeUserNotFound, "User not found", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to update user", err)
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, CodeInternalError, "Failed to retrieve updated user", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /api/users/{id}
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() {
+		RecordDBOperation("delete", "users", time.Since(start))
+	}()
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if current, ok := GetUser(r.Context()); ok && current.ID == id {
+		errorResponse(w, r, CodeSelfModificationForbidden, "You cannot delete your own account", nil)
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			errorResponse(w, r, CodeUserNotFound, "User not found", nil)
+			return
+		}
+		errorResponse(w, r, CodeInternalError, "Failed to delete user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MockUserRepository implements UserRepositoryInterface for testing
+type MockUserRepository struct {
+	users      map[string]*User
+	identities map[string]string // issuer + " " + subject -> user ID
+	bootstrap  string            // ID of the bootstrap admin
+	keys       *MockAPIKeyRepository
+	counter    int
+}
+
+func NewMockUserRepository(keys *MockAPIKeyRepository) *MockUserRepository {
+	return &MockUserRepository{
+		users:      make(map[string]*User),
+		identities: make(map[string]string),
+		keys:       keys,
+	}
+}
+
+func (m *MockUserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	for _, user := range m.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockUserRepository) GetByID(ctx context.Context, id string) (*User, error) {
+	return m.users[id], nil
+}
+
+func (m *MockUserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	return m.users[m.identities[issuer+" "+subject]], nil
+}
+
+func (m *MockUserRepository) GetBootstrapAdmin(ctx context.Context) (*User, error) {
+	return m.users[m.bootstrap], nil
+}
+
+func (m *MockUserRepository) SetBootstrapAdmin(ctx context.Context, id string) error {
+	if _, ok := m.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	m.bootstrap = id
+	return nil
+}
+
+func (m *MockUserRepository) CreateWithIdentity(ctx context.Context, user *User, issuer, subject string) error {
+	if err := m.Create(ctx, user, nil); err != nil {
+		return err
+	}
+	m.identities[issuer+" "+subject] = user.ID
+	return nil
+}
+
+func (m *MockUserRepository) Create(ctx context.Context, user *User, key *APIKey) error {
+	m.counter++
+	user.ID = fmt.Sprintf("user-%d", m.counter)
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+	m.users[user.ID] = user
+	if key == nil {
+		return nil
+	}
+	key.UserID = user.ID
+	return m.keys.Create(ctx, key)
+}
+
+func (m *MockUserRepository) List(ctx context.Context) ([]User, error) {
+	users := make([]User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, *user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+func (m *MockUserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	user, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Disabled = disabled
+	return nil
+}
+
+func (m *MockUserRepository) SetRole(ctx context.Context, id string, role string) error {
+	user, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.Role = role
+	return nil
+}
+
+func (m *MockUserRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := m.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.users, id)
+	for keyID, key := range m.keys.keys {
+		if key.UserID == id {
+			delete(m.keys.keys, keyID)
+		}
+	}
+	return nil
+}
+
+// setupUserTest builds a router wired like main with the real auth middleware
+func setupUserTest(t *testing.T) (*mux.Router, *MockUserRepository) {
+	t.Helper()
+
+	config := &Config{
+		BootstrapAdminKey:         "test-admin-key-123",
+		APIKeyHeader:              "X-API-Key",
+		Environment:               "test",
+		APIKeyRotationGracePeriod: time.Hour,
+	}
+
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	authMiddleware := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config)
+	userHandler := NewUserHandler(userRepo)
+	apiKeyHandler := NewAPIKeyHandler(apiKeyRepo, userRepo, config)
+
+	if err := authMiddleware.ReconcileBootstrapAdmin(context.Background()); err != nil {
+		t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+	}
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(authMiddleware.RequireAuthentication)
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	eventsRead := api.Methods("GET").Subrouter()
+	eventsRead.Use(authMiddleware.RequireScope(ScopeEventsRead))
+	eventsRead.HandleFunc("/events", ok)
+
+	eventsWrite := api.Methods("POST", "PUT", "DELETE").Subrouter()
+	eventsWrite.Use(authMiddleware.RequireScope(ScopeEventsWrite))
+	eventsWrite.HandleFunc("/events", ok).Methods("POST")
+
+	keys := api.PathPrefix("/users/{id}/keys").Subrouter()
+	keys.Use(authMiddleware.RequireScope(ScopeKeysManage))
+	keys.Use(authMiddleware.RequireSelfOrAdmin)
+	keys.HandleFunc("", apiKeyHandler.ListKeys).Methods("GET")
+	keys.HandleFunc("", apiKeyHandler.CreateKey).Methods("POST")
+	keys.HandleFunc("/{key_id}:revoke", apiKeyHandler.RevokeKey).Methods("POST")
+	keys.HandleFunc("/{key_id}:rotate", apiKeyHandler.RotateKey).Methods("POST")
+
+	users := api.PathPrefix("/users").Subrouter()
+	users.Use(authMiddleware.RequireScope(ScopeUsersAdmin))
+	users.Use(authMiddleware.RequireAdmin)
+	users.HandleFunc("", userHandler.ListUsers).Methods("GET")
+	users.HandleFunc("", userHandler.CreateUser).Methods("POST")
+	users.HandleFunc("/{id}:disable", userHandler.DisableUser).Methods("POST")
+	users.HandleFunc("/{id}:enable", userHandler.EnableUser).Methods("POST")
+	users.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
+	users.HandleFunc("/{id}", userHandler.UpdateUser).Methods("PATCH")
+	users.HandleFunc("/{id}", userHandler.DeleteUser).Methods("DELETE")
+
+	return r, userRepo
+}
+
+func TestUserManagement(t *testing.T) {
+	router, _ := setupUserTest(t)
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/users", "test-admin-key-123", `{"username":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Response: %s", w.Code, w.Body.String())
+	}
+
+	var created CreateUserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if created.ID == "" || !strings.HasPrefix(created.APIKey, apiKeyPrefix) {
+		t.Fatalf("Expected user with generated API key, got %+v", created)
+	}
+
+	t.Run("API key is not returned after creation", func(t *testing.T) {
+		w := serve("GET", "/api/users/"+created.ID, "test-admin-key-123", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), created.APIKey) || strings.Contains(w.Body.String(), "api_key") {
+			t.Errorf("Expected API key to be omitted, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("Duplicate username", func(t *testing.T) {
+		w := serve("POST", "/api/users", "test-admin-key-123", `{"username":"alice"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("Non-admin cannot manage users", func(t *testing.T) {
+		if w := serve("GET", "/api/users", created.APIKey, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", created.APIKey, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected new user to access events, got %d", w.Code)
+		}
+	})
+
+	t.Run("Disabled user cannot authenticate", func(t *testing.T) {
+		if w := serve("POST", "/api/users/"+created.ID+":disable", "test-admin-key-123", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", created.APIKey, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for disabled user, got %d", w.Code)
+		}
+		if w := serve("POST", "/api/users/"+created.ID+":enable", "test-admin-key-123", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", created.APIKey, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for re-enabled user, got %d", w.Code)
+		}
+	})
+
+	t.Run("List users", func(t *testing.T) {
+		w := serve("GET", "/api/users", "test-admin-key-123", "")
+		var response ListUsersResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Count != 2 || response.Users[0].Username != "admin" || response.Users[1].Username != "alice" {
+			t.Errorf("Expected admin and alice to be listed, got %+v", response)
+		}
+	})
+
+	t.Run("Delete user", func(t *testing.T) {
+		if w := serve("DELETE", "/api/users/"+created.ID, "test-admin-key-123", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/users/"+created.ID, "test-admin-key-123", ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 after delete, got %d", w.Code)
+		}
+		if w := serve("GET", "/api/events", created.APIKey, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected deleted user's key to be rejected, got %d", w.Code)
+		}
+	})
+}
+
+func TestAPIKeyHashing(t *testing.T) {
+	key, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+
+	if prefix := apiKeyLookupPrefix(key); len(prefix) != apiKeyLookupPrefixLength || !strings.HasPrefix(key, prefix) {
+		t.Errorf("Unexpected lookup prefix %q for key %q", prefix, key)
+	}
+	if prefix := apiKeyLookupPrefix("short"); prefix != "short" {
+		t.Errorf("Expected short keys to be their own prefix, got %q", prefix)
+	}
+
+	// Must match encode(sha256(...), 'hex') used by the key migration
+	if got := hashAPIKey("dev-test-key-123"); got != "50e68fbd39442d5886d5adce95c95614f45291687abf472a74f1c70d523b355a" {
+		t.Errorf("Unexpected SHA-256 hash %q", got)
+	}
+	if hashAPIKey(key) == hashAPIKey(key+"x") {
+		t.Error("Expected different keys to have different hashes")
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	router, userRepo := setupUserTest(t)
+	const adminKey = "test-admin-key-123"
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/users", adminKey, `{"username":"ops","role":"admin"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Response: %s", w.Code, w.Body.String())
+	}
+	var ops CreateUserResponse
+	json.Unmarshal(w.Body.Bytes(), &ops)
+	if ops.Role != RoleAdmin {
+		t.Fatalf("Expected admin role, got %q", ops.Role)
+	}
+
+	t.Run("Admin role grants user management", func(t *testing.T) {
+		if w := serve("GET", "/api/users", ops.APIKey, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		if w := serve("PATCH", "/api/users/"+ops.ID, adminKey, `{"role":"root"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("Demoted user loses admin access", func(t *testing.T) {
+		w := serve("PATCH", "/api/users/"+ops.ID, adminKey, `{"role":"service"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Response: %s", w.Code, w.Body.String())
+		}
+		if w := serve("GET", "/api/users", ops.APIKey, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("Cannot change own role", func(t *testing.T) {
+		admin, _ := userRepo.GetByUsername(context.Background(), bootstrapAdminUsername)
+		if w := serve("PATCH", "/api/users/"+admin.ID, adminKey, `{"role":"member"}`); w.Code != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", w.Code)
+		}
+	})
+}
+
+func TestReconcileBootstrapAdmin(t *testing.T) {
+	apiKeyRepo := NewMockAPIKeyRepository()
+	userRepo := NewMockUserRepository(apiKeyRepo)
+	config := &Config{BootstrapAdminKey: "first-admin-key-123", APIKeyHeader: "X-API-Key"}
+
+	reconcile := func() {
+		t.Helper()
+		if err := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config).ReconcileBootstrapAdmin(context.Background()); err != nil {
+			t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+		}
+	}
+
+	reconcile()
+	admin, _ := userRepo.GetByUsername(context.Background(), bootstrapAdminUsername)
+	if admin == nil || admin.Role != RoleAdmin {
+		t.Fatalf("Expected bootstrap admin with admin role, got %+v", admin)
+	}
+
+	// Reconciling again with the same key is a no-op
+	reconcile()
+	if keys, _ := apiKeyRepo.ListByUser(context.Background(), admin.ID); len(keys) != 1 {
+		t.Fatalf("Expected a single bootstrap key, got %d", len(keys))
+	}
+
+	// A demoted admin gets its role back and a changed key is rotated
+	userRepo.SetRole(context.Background(), admin.ID, RoleMember)
+	config.BootstrapAdminKey = "second-admin-key-456"
+	reconcile()
+
+	if admin, _ := userRepo.GetByID(context.Background(), admin.ID); admin.Role != RoleAdmin {
+		t.Errorf("Expected admin role to be restored, got %q", admin.Role)
+	}
+
+	oldKey, _ := apiKeyRepo.GetByKey(context.Background(), "first-admin-key-123")
+	if oldKey == nil || !oldKey.Revoked() {
+		t.Errorf("Expected previous bootstrap key to be revoked, got %+v", oldKey)
+	}
+
+	newKey, _ := apiKeyRepo.GetByKey(context.Background(), "second-admin-key-456")
+	if newKey == nil || newKey.Revoked() || newKey.UserID != admin.ID {
+		t.Errorf("Expected new bootstrap key for admin, got %+v", newKey)
+	}
+
+	// A disabled bootstrap admin is left disabled
+	userRepo.SetDisabled(context.Background(), admin.ID, true)
+	reconcile()
+	if admin, _ := userRepo.GetByID(context.Background(), admin.ID); !admin.Disabled {
+		t.Error("Expected the bootstrap admin to stay disabled")
+	}
+
+	// A revoked key cannot be brought back through configuration
+	config.BootstrapAdminKey = "first-admin-key-123"
+	if err := NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config).ReconcileBootstrapAdmin(context.Background()); err == nil {
+		t.Error("Expected reconciling with a revoked key to fail")
+	}
+}
+
+func TestReconcileBootstrapAdminIgnoresUsername(t *testing.T) {
+	reconcile := func(userRepo *MockUserRepository, apiKeyRepo *MockAPIKeyRepository) error {
+		config := &Config{BootstrapAdminKey: "bootstrap-admin-key-123", APIKeyHeader: "X-API-Key"}
+		return NewAuthMiddleware(userRepo, apiKeyRepo, NewAuthFailureTracker(NewMemoryAuthFailureStore(), config), config).ReconcileBootstrapAdmin(context.Background())
+	}
+
+	t.Run("Existing account named admin is left alone", func(t *testing.T) {
+		apiKeyRepo := NewMockAPIKeyRepository()
+		userRepo := NewMockUserRepository(apiKeyRepo)
+		userRepo.Create(context.Background(), &User{Username: "root", Role: RoleAdmin}, nil)
+		impostor := &User{Username: bootstrapAdminUsername, Disabled: true}
+		userRepo.Create(context.Background(), impostor, nil)
+
+		if err := reconcile(userRepo, apiKeyRepo); err != nil {
+			t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+		}
+		if impostor.Role != RoleMember || !impostor.Disabled {
+			t.Errorf("Expected the account to keep its role and status, got %+v", impostor)
+		}
+		if bootstrap, _ := userRepo.GetBootstrapAdmin(context.Background()); bootstrap != nil {
+			t.Errorf("Expected no bootstrap admin while another admin exists, got %+v", bootstrap)
+		}
+	})
+
+	t.Run("Taken username without any admin", func(t *testing.T) {
+		apiKeyRepo := NewMockAPIKeyRepository()
+		userRepo := NewMockUserRepository(apiKeyRepo)
+		impostor := &User{Username: bootstrapAdminUsername}
+		userRepo.Create(context.Background(), impostor, nil)
+
+		if err := reconcile(userRepo, apiKeyRepo); err == nil {
+			t.Error("Expected reconciling to fail when the username is taken")
+		}
+		if impostor.Role != RoleMember {
+			t.Errorf("Expected the account not to be promoted, got %q", impostor.Role)
+		}
+	})
+
+	t.Run("Holder of the bootstrap key is adopted", func(t *testing.T) {
+		apiKeyRepo := NewMockAPIKeyRepository()
+		userRepo := NewMockUserRepository(apiKeyRepo)
+		legacy := &User{Username: "operator"}
+		userRepo.Create(context.Background(), legacy, &APIKey{Name: "default", Key: "bootstrap-admin-key-123", Scopes: []string{ScopeAll}})
+
+		if err := reconcile(userRepo, apiKeyRepo); err != nil {
+			t.Fatalf("Failed to reconcile bootstrap admin: %v", err)
+		}
+		bootstrap, _ := userRepo.GetBootstrapAdmin(context.Background())
+		if bootstrap == nil || bootstrap.ID != legacy.ID || legacy.Role != RoleAdmin {
+			t.Errorf("Expected the key holder to be flagged as admin, got %+v", bootstrap)
+		}
+	})
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// tagAfterStartTime is reported on end_time when it is not after start_time
+const tagAfterStartTime = "after_start_time"
+
+// indexPattern matches slice indexes in validator namespaces, such as "[0]"
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// validationMessages holds the validation messages of each supported
+// locale. Keys are validator tags, suffixed with "-items" where a limit
+// applies to a list rather than a string, or "type-" and a JSON type for
+// values of the wrong type.
+var validationMessages = map[locales.Translator]map[string]string{
+	en.New(): {
+		"required":        "is required",
+		"min":             "must be at least {0} characters",
+		"min-items":       "must contain at least {0} items",
+		"max":             "must be at most {0} characters",
+		"max-items":       "must contain at most {0} items",
+		"oneof":           "must be one of: {0}",
+		"datetime":        "must be an RFC 3339 timestamp, such as 2030-01-02T15:04:05Z",
+		tagAfterStartTime: "must be after start_time",
+		"type-string":     "must be a string",
+		"type-number":     "must be a number",
+		"type-boolean":    "must be a boolean",
+		"type-array":      "must be an array",
+		"type-object":     "must be an object",
+		"invalid":         "is invalid",
+	},
+	es.New(): {
+		"required":        "es obligatorio",
+		"min":             "debe tener al menos {0} caracteres",
+		"min-items":       "debe contener al menos {0} elementos",
+		"max":             "debe tener como máximo {0} caracteres",
+		"max-items":       "debe contener como máximo {0} elementos",
+		"oneof":           "debe ser uno de: {0}",
+		"datetime":        "debe ser una marca de tiempo RFC 3339, como 2030-01-02T15:04:05Z",
+		tagAfterStartTime: "debe ser posterior a start_time",
+		"type-string":     "debe ser una cadena",
+		"type-number":     "debe ser un número",
+		"type-boolean":    "debe ser un booleano",
+		"type-array":      "debe ser una lista",
+		"type-object":     "debe ser un objeto",
+		"invalid":         "no es válido",
+	},
+	fr.New(): {
+		"required":        "est obligatoire",
+		"min":             "doit contenir au moins {0} caractères",
+		"min-items":       "doit contenir au moins {0} éléments",
+		"max":             "doit contenir au plus {0} caractères",
+		"max-items":       "doit contenir au plus {0} éléments",
+		"oneof":           "doit être l'une des valeurs : {0}",
+		"datetime":        "doit être un horodatage RFC 3339, par exemple 2030-01-02T15:04:05Z",
+		tagAfterStartTime: "doit être postérieur à start_time",
+		"type-string":     "doit être une chaîne de caractères",
+		"type-number":     "doit être un nombre",
+		"type-boolean":    "doit être un booléen",
+		"type-array":      "doit être une liste",
+		"type-object":     "doit être un objet",
+		"invalid":         "n'est pas valide",
+	},
+}
+
+// RequestValidator decodes and validates request bodies, reporting each
+// failure by JSON pointer in the language the client asks for
+type RequestValidator struct {
+	validate    *validator.Validate
+	translators *ut.UniversalTranslator
+}
+
+// NewRequestValidator creates a request validator. English is used when
+// Accept-Language names no supported locale.
+func NewRequestValidator() *RequestValidator {
+	validate := validator.New()
+
+	// Report fields by their JSON names
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	validate.RegisterStructValidation(validateEventTimeRange, CreateEventRequest{}, UpdateEventRequest{})
+
+	fallback := en.New()
+	translators := ut.New(fallback, fallback)
+	for locale, messages := range validationMessages {
+		if locale.Locale() != fallback.Locale() {
+			if err := translators.AddTranslator(locale, false); err != nil {
+				panic(fmt.Sprintf("failed to add %s translator: %v", locale.Locale(), err))
+			}
+		}
+		trans, _ := translators.GetTranslator(locale.Locale())
+		for key, text := range messages {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(fmt.Sprintf("invalid %s validation message %q: %v", locale.Locale(), key, err))
+			}
+		}
+	}
+
+	return &RequestValidator{validate: validate, translators: translators}
+}
+
+// validateEventTimeRange checks that an event ends after it starts. Times
+// that do not parse are left to the datetime tag.
+func validateEventTimeRange(sl validator.StructLevel) {
+	var startTime, endTime string
+	switch req := sl.Current().Interface().(type) {
+	case CreateEventRequest:
+		startTime, endTime = req.StartTime, req.EndTime
+	case UpdateEventRequest:
+		startTime, endTime = req.StartTime, req.EndTime
+	}
+
+	start, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return
+	}
+	end, err := time.Parse(time.RFC3339, endTime)
+	if err != nil {
+		return
+	}
+	if !end.After(start) {
+		sl.ReportError(endTime, "end_time", "EndTime", tagAfterStartTime, "")
+	}
+}
+
+// Struct validates s against its validate tags
+func (v *RequestValidator) Struct(s interface{}) error {
+	return v.validate.Struct(s)
+}
+
+// Translator returns the translator for the most preferred locale in the
+// request's Accept-Language header
+func (v *RequestValidator) Translator(r *http.Request) ut.Translator {
+	type languageRange struct {
+		locale string
+		q      float64
+	}
+
+	var ranges []languageRange
+	for _, header := range r.Header.Values("Accept-Language") {
+		for _, part := range strings.Split(header, ",") {
+			tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			q := 1.0
+			if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil || parsed <= 0 {
+					continue
+				}
+				q = parsed
+			}
+			if tag != "" && tag != "*" {
+				ranges = append(ranges, languageRange{locale: tag, q: q})
+			}
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	// Try each range, then its primary language ("fr" for "fr-CA")
+	candidates := make([]string, 0, 2*len(ranges))
+	for _, lr := range ranges {
+		locale := strings.ReplaceAll(lr.locale, "-", "_")
+		candidates = append(candidates, locale)
+		if language, _, found := strings.Cut(locale, "_"); found {
+			candidates = append(candidates, language)
+		}
+	}
+
+	trans, _ := v.translators.FindTranslator(candidates...)
+	return trans
+}
+
+// Describe maps each field that failed validation, by JSON pointer, to a
+// message in the translator's language
+func (v *RequestValidator) Describe(trans ut.Translator, err error) map[string]string {
+	details := make(map[string]string)
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		details["body"] = translate(trans, "invalid")
+		return details
+	}
+
+	for _, e := range validationErrors {
+		details[fieldPointer(e.Namespace())] = describeFieldError(trans, e)
+	}
+	return details
+}
+
+// Decode strictly decodes the JSON request body into dst, as described by
+// decodeJSONBody, and validates it. On failure it writes the error response
+// and returns false. A value of the wrong JSON type is reported alongside
+// any other validation failures.
+func (v *RequestValidator) Decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return v.decode(w, r, dst, false)
+}
+
+// DecodeOptional is Decode for endpoints whose body may be omitted, in which
+// case dst is validated as it is
+func (v *RequestValidator) DecodeOptional(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return v.decode(w, r, dst, true)
+}
+
+func (v *RequestValidator) decode(w http.ResponseWriter, r *http.Request, dst interface{}, optional bool) bool {
+	trans := v.Translator(r)
+	details := make(map[string]string)
+
+	err := decodeJSONBody(r, dst, optional)
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		details[typePointer(typeErr)] = translate(trans, "type-"+jsonTypeName(typeErr.Type))
+	case err != nil:
+		bodyErrorResponse(w, r, err)
+		return false
+	}
+
+	if validationErr := v.validate.Struct(dst); validationErr != nil {
+		for pointer, message := range v.Describe(trans, validationErr) {
+			// A value of the wrong type is left empty, which is not worth
+			// reporting a second time
+			if _, ok := details[pointer]; !ok {
+				details[pointer] = message
+			}
+		}
+		if err == nil {
+			err = validationErr
+		}
+	}
+
+	if len(details) == 0 {
+		return true
+	}
+	v.errorResponse(w, r, trans, details, err)
+	return false
+}
+
+// errorResponse writes a validation failure response in the translator's
+// language
+func (v *RequestValidator) errorResponse(w http.ResponseWriter, r *http.Request, trans ut.Translator, details map[string]string, err error) {
+	w.Header().Set("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+	errorDetailsResponse(w, r, CodeValidationFailed, "Request validation failed", details, err)
+}
+
+// describeValidationError flattens validation failures into a single English
+// message, for contexts that cannot report them per field
+func (v *RequestValidator) describeValidationError(err error) string {
+	details := v.Describe(v.translators.GetFallback(), err)
+
+	pointers := make([]string, 0, len(details))
+	for pointer := range details {
+		pointers = append(pointers, pointer)
+	}
+	sort.Strings(pointers)
+
+	parts := make([]string, 0, len(pointers))
+	for _, pointer := range pointers {
+		parts = append(parts, pointer+" "+details[pointer])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeFieldError translates a single validator failure, formatting its
+// parameter for the translator's locale
+func describeFieldError(trans ut.Translator, e validator.FieldError) string {
+	switch e.Tag() {
+	case "required", "datetime", tagAfterStartTime:
+		return translate(trans, e.Tag())
+	case "min", "max":
+		key := e.Tag()
+		if kind := e.Kind(); kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map {
+			key += "-items"
+		}
+		limit, err := strconv.ParseFloat(e.Param(), 64)
+		if err != nil {
+			return translate(trans, key, e.Param())
+		}
+		return translate(trans, key, trans.FmtNumber(limit, 0))
+	case "oneof":
+		return translate(trans, "oneof", strings.Join(strings.Fields(e.Param()), ", "))
+	default:
+		return translate(trans, "invalid")
+	}
+}
+
+// translate looks up key for the translator's locale
+func translate(trans ut.Translator, key string, params ...string) string {
+	message, err := trans.T(key, params...)
+	if err != nil {
+		return key
+	}
+	return message
+}
+
+// fieldPointer converts a validator namespace, such as
+// "BatchRequest.operations[0].op", into a JSON pointer, "/operations/0/op"
+func fieldPointer(namespace string) string {
+	_, path, _ := strings.Cut(namespace, ".")
+	path = indexPattern.ReplaceAllString(path, ".$1")
+	return "/" + strings.ReplaceAll(path, ".", "/")
+}
+
+// typePointer returns the JSON pointer of a value of the wrong type, or
+// "body" when the body itself has the wrong type
+func typePointer(e *json.UnmarshalTypeError) string {
+	if e.Field == "" {
+		return "body"
+	}
+	return "/" + strings.ReplaceAll(e.Field, ".", "/")
+}
+
+// jsonTypeName names the JSON type that decodes into t
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateEventValidationDetails(t *testing.T) {
+	handler, _ := setupEventTest(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		language string
+		details  map[string]string
+	}{
+		{
+			name: "Every failure reported by JSON field",
+			body: `{"title":"","start_time":"2030-01-01T10:00:00Z","end_time":"tomorrow"}`,
+			details: map[string]string{
+				"/title":    "is required",
+				"/end_time": "must be an RFC 3339 timestamp, such as 2030-01-02T15:04:05Z",
+			},
+		},
+		{
+			name: "Length limit with parameter",
+			body: `{"title":"` + strings.Repeat("a", 256) + `","description":"` + strings.Repeat("b", 1001) + `","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`,
+			details: map[string]string{
+				"/title":       "must be at most 255 characters",
+				"/description": "must be at most 1,000 characters",
+			},
+		},
+		{
+			name: "Time range alongside other failures",
+			body: `{"title":"","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T09:00:00Z"}`,
+			details: map[string]string{
+				"/title":    "is required",
+				"/end_time": "must be after start_time",
+			},
+		},
+		{
+			name: "JSON type error alongside other failures",
+			body: `{"title":42,"start_time":"2030-01-01T10:00:00Z"}`,
+			details: map[string]string{
+				"/title":    "must be a string",
+				"/end_time": "is required",
+			},
+		},
+		{
+			name:     "Translated messages",
+			body:     `{"title":"` + strings.Repeat("a", 256) + `","start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T09:00:00Z"}`,
+			language: "fr-CA, en;q=0.5",
+			details: map[string]string{
+				"/title":    "doit contenir au plus 255 caractères",
+				"/end_time": "doit être postérieur à start_time",
+			},
+		},
+		{
+			name:     "Unsupported language falls back to English",
+			body:     `{"start_time":"2030-01-01T10:00:00Z","end_time":"2030-01-01T11:00:00Z"}`,
+			language: "de-DE, es;q=0",
+			details: map[string]string{
+				"/title": "is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/events", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+
+			w := httptest.NewRecorder()
+			handler.CreateEvent(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Code != CodeValidationFailed {
+				t.Errorf("Expected code %s, got %s", CodeValidationFailed, response.Code)
+			}
+			if len(response.Details) != len(tt.details) {
+				t.Errorf("Expected details %v, got %v", tt.details, response.Details)
+			}
+			for field, message := range tt.details {
+				if response.Details[field] != message {
+					t.Errorf("Expected %s to be %q, got %q", field, message, response.Details[field])
+				}
+			}
+		})
+	}
+}
+
+func TestRequestValidatorTranslator(t *testing.T) {
+	v := NewRequestValidator()
+
+	tests := []struct {
+		header string
+		locale string
+	}{
+		{header: "", locale: "en"},
+		{header: "es", locale: "es"},
+		{header: "es-MX", locale: "es"},
+		{header: "de, fr;q=0.8", locale: "fr"},
+		{header: "en;q=0.3, fr;q=0.9", locale: "fr"},
+		{header: "fr;q=0, es;q=0.1", locale: "es"},
+		{header: "*", locale: "en"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/events", nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Language", tt.header)
+		}
+		if locale := v.Translator(req).Locale(); locale != tt.locale {
+			t.Errorf("Accept-Language %q: expected locale %s, got %s", tt.header, tt.locale, locale)
+		}
+	}
+}
+
+func TestFieldPointer(t *testing.T) {
+	tests := map[string]string{
+		"CreateEventRequest.title":       "/title",
+		"BatchRequest.operations[0].op":  "/operations/0/op",
+		"CreateAPIKeyRequest.scopes[12]": "/scopes/12",
+	}
+
+	for namespace, expected := range tests {
+		if pointer := fieldPointer(namespace); pointer != expected {
+			t.Errorf("fieldPointer(%q) = %q, expected %q", namespace, pointer, expected)
+		}
+	}
+}
@@ -104,6 +104,12 @@ resource "aws_ecs_task_definition" "app" {
       {
         name  = "PORT"
         value = tostring(var.app_port)
+      },
+      {
+        # The ALB connects from its public subnets and appends the client
+        # address to X-Forwarded-For
+        name  = "TRUSTED_PROXIES"
+        value = join(",", aws_subnet.public[*].cidr_block)
       }
     ]
 
@@ -130,7 +136,7 @@ resource "aws_ecs_task_definition" "app" {
     }
 
     healthCheck = {
-      command     = ["CMD-SHELL", "exit 0"]
+      command     = ["CMD-SHELL", "curl -f http://localhost:${var.app_port}/livez || exit 1"]
       interval    = 10
       timeout     = 5
       retries     = 3